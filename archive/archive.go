@@ -23,6 +23,9 @@ type metadata struct {
 	Files   []string
 	Builds  []Build
 	Missing map[string][]string `json:",omitempty"`
+	// Optional hex-encoded SHA-256 digests of files, mapped by GUID, then by
+	// file name.
+	Checksums map[string]map[string]string `json:",omitempty"`
 }
 
 type Build struct {
@@ -49,6 +52,32 @@ func NewRepo(source fs.FS) (repo *Repo, err error) {
 	return repo, nil
 }
 
+// Returns a new Repo from a source location. The location is opened with the
+// Source registered with its scheme. A location without a scheme is a file
+// path to either the data directory of a build archive, or a zip or tar.gz
+// file containing it.
+func OpenRepo(source string) (repo *Repo, err error) {
+	fsys, err := OpenSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if repo, err = NewRepo(fsys); err != nil {
+		if c, ok := fsys.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Closes the source of the repo, if it can be closed.
+func (r *Repo) Close() error {
+	if c, ok := r.fs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // Fetches metadata.
 func (r *Repo) fetchData() error {
 	d := data{metadata: map[string]metadata{}}
@@ -115,6 +144,24 @@ func (r *Repo) Builds() (builds []Build) {
 	return builds
 }
 
+// Returns the names of groups, in the order listed by the repo.
+func (r *Repo) Groups() []string {
+	if r.d == nil {
+		return nil
+	}
+	return slices.Clone(r.d.groups)
+}
+
+// Returns the names of files listed by the group of a build. Files listed by
+// the group may still be missing from the build, which can be determined with
+// Exists.
+func (r *Repo) Files(build Build) []string {
+	if r.d == nil {
+		return nil
+	}
+	return slices.Clone(r.d.metadata[build.Group].Files)
+}
+
 // Returns whether a file exists without making any FS calls.
 func (r *Repo) Exists(build Build, name string) bool {
 	if r.d == nil {
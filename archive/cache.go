@@ -0,0 +1,185 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A file system that reads a build archive from a remote source via HTTP,
+// keeping a copy of each file in a local directory.
+//
+// The files of a build are assumed to never change, so a cached build file is
+// used without making a request. Other files, such as groups.json and
+// metadata.json, are revalidated on each open using the ETag or Last-Modified
+// headers returned by the server.
+type HTTPCache struct {
+	// URL of the data directory of the build archive.
+	URL *url.URL
+	// Directory in which files are cached. Files are stored under the same
+	// paths as in the archive, so that each build file is keyed by group, GUID,
+	// and file name.
+	Dir string
+	// Client used to make requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Validators of a cached file, stored next to the file.
+type cacheInfo struct {
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+}
+
+// Suffix of files containing the cacheInfo of a cached file.
+const cacheInfoSuffix = ".cache.json"
+
+// Returns a Source that opens HTTP locations with an HTTPCache. The files of
+// each location are cached in a subdirectory of dir derived from the host and
+// path of the location.
+func CachedHTTPSource(dir string) Source {
+	return func(location string) (fs.FS, error) {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		return &HTTPCache{
+			URL: u,
+			// Colons are not permitted in Windows file names.
+			Dir: filepath.Join(dir, strings.ReplaceAll(u.Host, ":", "_"), filepath.FromSlash(path.Clean("/"+u.Path))),
+		}, nil
+	}
+}
+
+// Returns whether the file at name is the file of a build, which never
+// changes.
+func isBuildFile(name string) bool {
+	parts := strings.Split(name, "/")
+	return len(parts) == 4 && parts[1] == "builds"
+}
+
+func (c *HTTPCache) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return http.DefaultClient
+}
+
+// Opens the file at name, fetching it from the remote source if it is not
+// cached, or if the cached file is stale.
+func (c *HTTPCache) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	local := filepath.Join(c.Dir, filepath.FromSlash(name))
+	if isBuildFile(name) {
+		if f, err := os.Open(local); err == nil {
+			return f, nil
+		}
+	}
+	if err := c.fetch(name, local); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.Open(local)
+}
+
+// Fetches the file at name into the local path. If the file is already
+// cached, then the request is made conditional on the file having changed.
+func (c *HTTPCache) fetch(name, local string) error {
+	u := c.URL.JoinPath(name)
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	var info cacheInfo
+	if _, err := os.Stat(local); err == nil {
+		if b, err := os.ReadFile(local + cacheInfoSuffix); err == nil {
+			json.Unmarshal(b, &info)
+		}
+		if info.ETag != "" {
+			req.Header.Set("If-None-Match", info.ETag)
+		}
+		if info.LastModified != "" {
+			req.Header.Set("If-Modified-Since", info.LastModified)
+		}
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	default:
+		return fmt.Errorf("get %s: %s", u, resp.Status)
+	}
+
+	if err := writeFile(local, resp.Body); err != nil {
+		return err
+	}
+	info = cacheInfo{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if isBuildFile(name) {
+		return nil
+	}
+	if info == (cacheInfo{}) {
+		// Nothing to revalidate with; always refetch.
+		if err := os.Remove(local + cacheInfoSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(local+cacheInfoSuffix, b, 0666)
+}
+
+// Writes the content of r to the file at name. The file is written completely
+// before replacing any existing file, so that an interrupted write does not
+// leave a partial file in the cache.
+func writeFile(name string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Temporary files are created with restricted permissions.
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package archive
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// A server of archive files that records the requests it receives.
+type testServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	files    map[string]string
+	requests []*http.Request
+	// Validators sent with each file.
+	etag         string
+	lastModified string
+	// If true, then responses are aborted after sending part of the body.
+	abort bool
+}
+
+func newTestServer(t *testing.T, files map[string]string) *testServer {
+	s := &testServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	content, ok := s.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if s.lastModified != "" {
+		w.Header().Set("Last-Modified", s.lastModified)
+		if r.Header.Get("If-Modified-Since") == s.lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if s.abort {
+		w.Header().Set("Content-Length", "1000")
+		io.WriteString(w, content)
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	io.WriteString(w, content)
+}
+
+// Returns the requests received since the last call.
+func (s *testServer) take() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func newTestCache(t *testing.T, s *testServer) *HTTPCache {
+	u, err := url.Parse(s.URL + "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HTTPCache{URL: u, Dir: t.TempDir(), Client: s.Client()}
+}
+
+func readCached(t *testing.T, c *HTTPCache, name string) string {
+	t.Helper()
+	b, err := fs.ReadFile(c, name)
+	if err != nil {
+		t.Fatalf("read %s: %s", name, err)
+	}
+	return string(b)
+}
+
+func TestHTTPCacheBuildFile(t *testing.T) {
+	const name = "Player/builds/version-0123/API-Dump.json"
+	s := newTestServer(t, map[string]string{"/data/" + name: "dump"})
+	c := newTestCache(t, s)
+
+	if got := readCached(t, c, name); got != "dump" {
+		t.Fatalf("expected content %q, got %q", "dump", got)
+	}
+	if n := len(s.take()); n != 1 {
+		t.Fatalf("expected 1 request, got %d", n)
+	}
+	if got := readCached(t, c, name); got != "dump" {
+		t.Fatalf("expected content %q, got %q", "dump", got)
+	}
+	if n := len(s.take()); n != 0 {
+		t.Fatalf("expected cached build file to make no requests, got %d", n)
+	}
+}
+
+func TestHTTPCacheRevalidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		etag         string
+		lastModified string
+		header       string
+	}{
+		{name: "ETag", etag: `"v1"`, header: "If-None-Match"},
+		{name: "LastModified", lastModified: "Mon, 02 Jan 2006 15:04:05 GMT", header: "If-Modified-Since"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t, map[string]string{"/data/groups.json": `["Player"]`})
+			s.etag = test.etag
+			s.lastModified = test.lastModified
+			c := newTestCache(t, s)
+
+			readCached(t, c, "groups.json")
+			if requests := s.take(); len(requests) != 1 || requests[0].Header.Get(test.header) != "" {
+				t.Fatalf("expected 1 unconditional request")
+			}
+
+			// Changes on the server are not visible if the file is not
+			// modified according to the validator.
+			s.mu.Lock()
+			s.files["/data/groups.json"] = `["Changed"]`
+			s.mu.Unlock()
+			if got := readCached(t, c, "groups.json"); got != `["Player"]` {
+				t.Fatalf("expected cached content, got %q", got)
+			}
+			requests := s.take()
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(requests))
+			}
+			if requests[0].Header.Get(test.header) == "" {
+				t.Fatalf("expected request to have %s header", test.header)
+			}
+
+			// A changed validator causes the file to be refetched.
+			s.mu.Lock()
+			s.etag += "2"
+			if s.lastModified != "" {
+				s.lastModified = "Tue, 03 Jan 2006 15:04:05 GMT"
+			}
+			s.mu.Unlock()
+			if got := readCached(t, c, "groups.json"); got != `["Changed"]` {
+				t.Fatalf("expected refetched content, got %q", got)
+			}
+		})
+	}
+}
+
+func TestHTTPCacheNotFound(t *testing.T) {
+	s := newTestServer(t, map[string]string{})
+	c := newTestCache(t, s)
+	for _, name := range []string{"groups.json", "Player/builds/version-0123/API-Dump.json"} {
+		if _, err := c.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected fs.ErrNotExist, got %v", name, err)
+		}
+	}
+}
+
+func TestHTTPCacheInterruptedWrite(t *testing.T) {
+	const name = "Player/builds/version-0123/API-Dump.json"
+	s := newTestServer(t, map[string]string{"/data/" + name: "partial"})
+	s.abort = true
+	c := newTestCache(t, s)
+
+	if _, err := c.Open(name); err == nil {
+		t.Fatal("expected error from interrupted response")
+	}
+	dir := filepath.Join(c.Dir, filepath.FromSlash(path.Dir(name)))
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("expected no files, found %s", entry.Name())
+	}
+
+	// The next open fetches the complete file.
+	s.mu.Lock()
+	s.abort = false
+	s.mu.Unlock()
+	if got := readCached(t, c, name); got != "partial" {
+		t.Fatalf("expected content %q, got %q", "partial", got)
+	}
+}
@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robloxapi/rbxver"
+)
+
+// Indicates that no build matches a query.
+var ErrBuildNotFound = errors.New("build not found")
+
+// Indicates that a query matches several distinct builds.
+type AmbiguousError struct {
+	Query string
+	// The builds matching the query.
+	Builds []Build
+}
+
+// Maximum number of candidates listed by AmbiguousError.
+const maxCandidates = 5
+
+func (err AmbiguousError) Error() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s: ambiguous; matches %d builds:", err.Query, len(err.Builds))
+	for i, build := range err.Builds {
+		if i >= maxCandidates {
+			fmt.Fprintf(&s, " ...")
+			break
+		}
+		fmt.Fprintf(&s, " %s", build.GUID)
+	}
+	return s.String()
+}
+
+// Returns the build referred to by query, which is one of the following:
+//
+//   - "latest": The latest build.
+//   - "previous": The latest build with a GUID that differs from the latest
+//     build.
+//   - A version, such as "0.612.0.6120532". If several builds have the
+//     version, then the latest is returned.
+//   - A date of the form YYYY-MM-DD, or an RFC 3339 timestamp. The latest build
+//     dated at or before the date is returned. A date without a time includes
+//     the entire day.
+//   - A GUID, or a prefix of a GUID that is unique among all builds. The
+//     "version-" prefix may be omitted.
+//
+// Returns ErrBuildNotFound if no build matches, or an AmbiguousError if a GUID
+// prefix matches several builds.
+func (r *Repo) Find(query string) (build Build, err error) {
+	builds := r.Builds()
+	i, err := findIndex(builds, query)
+	if err != nil {
+		return Build{}, err
+	}
+	return builds[i], nil
+}
+
+// Returns the index of the element of items referred to by query, as described
+// by Repo.Find. The build function returns the build corresponding to an
+// element. items must be ordered by date.
+func FindIndex[T any](items []T, query string, build func(T) Build) (int, error) {
+	builds := make([]Build, len(items))
+	for i, item := range items {
+		builds[i] = build(item)
+	}
+	return findIndex(builds, query)
+}
+
+// Returns the index of the build referred to by query.
+func findIndex(builds []Build, query string) (int, error) {
+	if len(builds) == 0 {
+		return -1, fmt.Errorf("%s: %w", query, ErrBuildNotFound)
+	}
+	switch query {
+	case "latest":
+		return len(builds) - 1, nil
+	case "previous":
+		latest := builds[len(builds)-1]
+		for i := len(builds) - 2; i >= 0; i-- {
+			if builds[i].GUID != latest.GUID {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%s: %w", query, ErrBuildNotFound)
+	}
+
+	if date, err := time.Parse(time.DateOnly, query); err == nil {
+		return findBefore(builds, query, date.AddDate(0, 0, 1))
+	}
+	if date, err := time.Parse(time.RFC3339, query); err == nil {
+		return findBefore(builds, query, date.Add(time.Nanosecond))
+	}
+
+	if version := rbxver.Parse(query, rbxver.Any); version.Format != 0 {
+		for i := len(builds) - 1; i >= 0; i-- {
+			if builds[i].Version.Compare(version) == 0 {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%s: %w", query, ErrBuildNotFound)
+	}
+
+	prefix := strings.ToLower(query)
+	if !strings.HasPrefix(prefix, "version-") {
+		prefix = "version-" + prefix
+	}
+	var matches []Build
+	exact, latest := -1, -1
+	for i, b := range builds {
+		if b.GUID == query {
+			// Exact match; prefer the latest build with the GUID.
+			exact = i
+		}
+		if strings.HasPrefix(strings.ToLower(b.GUID), prefix) {
+			matches = append(matches, b)
+			latest = i
+		}
+	}
+	if exact >= 0 {
+		return exact, nil
+	}
+	if len(matches) == 0 {
+		return -1, fmt.Errorf("%s: %w", query, ErrBuildNotFound)
+	}
+	// Builds with the same GUID are not ambiguous.
+	for _, b := range matches {
+		if b.GUID != builds[latest].GUID {
+			return -1, AmbiguousError{Query: query, Builds: uniqueGUIDs(matches)}
+		}
+	}
+	return latest, nil
+}
+
+// Returns the index of the latest of builds dated before t.
+func findBefore(builds []Build, query string, t time.Time) (int, error) {
+	for i := len(builds) - 1; i >= 0; i-- {
+		if builds[i].Date.Before(t) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%s: no build at or before date: %w", query, ErrBuildNotFound)
+}
+
+// Returns the latest build of each distinct GUID within builds, ordered by
+// date.
+func uniqueGUIDs(builds []Build) []Build {
+	latest := map[string]int{}
+	for i, b := range builds {
+		latest[b.GUID] = i
+	}
+	var unique []Build
+	for i, b := range builds {
+		if latest[b.GUID] == i {
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
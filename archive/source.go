@@ -0,0 +1,370 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/publysher/httpfs"
+)
+
+// Opens the file system of a source location. The file system is expected to
+// comply with the build archive format, rooted at the data directory. If the
+// file system implements io.Closer, then it is closed when the Repo using it is
+// closed.
+type Source func(location string) (fs.FS, error)
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]Source{}
+)
+
+// Registers source as the opener of locations with the given URL scheme.
+// Replaces any source already registered with the scheme.
+func RegisterSource(scheme string, source Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources[strings.ToLower(scheme)] = source
+}
+
+// Returns the schemes of all registered sources, in lexicographical order.
+func Sources() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	schemes := make([]string, 0, len(sources))
+	for scheme := range sources {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func init() {
+	RegisterSource("file", openDir)
+	RegisterSource("http", openHTTP)
+	RegisterSource("https", openHTTP)
+	RegisterSource("zip", openZip)
+	RegisterSource("tar.gz", openTarGz)
+}
+
+// Returns the scheme of location. A location without a registered scheme is
+// considered to be a file path, in which case the scheme is derived from the
+// extension of the file.
+func sourceScheme(location string) string {
+	if u, err := url.Parse(location); err == nil {
+		sourcesMu.RLock()
+		_, ok := sources[strings.ToLower(u.Scheme)]
+		sourcesMu.RUnlock()
+		// Single-letter schemes are likely Windows drive letters.
+		if ok && len(u.Scheme) > 1 {
+			return strings.ToLower(u.Scheme)
+		}
+	}
+	switch name := strings.ToLower(location); {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return "file"
+}
+
+// Opens the file system of location using the source registered with the
+// scheme of the location.
+func OpenSource(location string) (fs.FS, error) {
+	scheme := sourceScheme(location)
+	sourcesMu.RLock()
+	source, ok := sources[scheme]
+	sourcesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no source registered for scheme %q", scheme)
+	}
+	return source(location)
+}
+
+// Returns the file path of a location, which may be a plain path, or a URL
+// such as "file:///path/to/data" or "zip:archive.zip".
+func localPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || len(u.Scheme) <= 1 {
+		return location
+	}
+	if u.Opaque != "" {
+		return filepath.FromSlash(u.Opaque)
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Opens a directory of the local file system.
+func openDir(location string) (fs.FS, error) {
+	return os.DirFS(localPath(location)), nil
+}
+
+// Opens a remote source via HTTP.
+func openHTTP(location string) (fs.FS, error) {
+	// The path must have a trailing slash.
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	return httpfs.NewFS(u), nil
+}
+
+// Opens a zip file containing a build archive.
+func openZip(location string) (fs.FS, error) {
+	zr, err := zip.OpenReader(localPath(location))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(zr.File))
+	for i, f := range zr.File {
+		names[i] = f.Name
+	}
+	root, err := dataRoot(names)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	sub, err := fs.Sub(zr, root)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return subCloser{FS: sub, Closer: zr}, nil
+}
+
+// A file system that closes an underlying resource.
+type subCloser struct {
+	fs.FS
+	io.Closer
+}
+
+// Returns the directory containing the groups.json file nearest to the root of
+// an archive with the given file names. This allows the archive to contain the
+// data directory at any depth, such as when a repository is archived with a
+// top-level directory.
+func dataRoot(names []string) (string, error) {
+	root := ""
+	depth := -1
+	for _, name := range names {
+		name = strings.TrimPrefix(path.Clean(name), "/")
+		if path.Base(name) != "groups.json" {
+			continue
+		}
+		d := strings.Count(name, "/")
+		if depth < 0 || d < depth {
+			root, depth = path.Dir(name), d
+		}
+	}
+	if depth < 0 {
+		return "", errors.New("groups.json not found in archive")
+	}
+	return root, nil
+}
+
+// Opens a gzip-compressed tar file containing a build archive. Since tar files
+// cannot be read randomly, the contents of regular files are decompressed into
+// a temporary file, which is removed when the file system is closed.
+func openTarGz(location string) (fs.FS, error) {
+	f, err := os.Open(localPath(location))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	tmp, err := os.CreateTemp("", "roar-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	tfs := &tarFS{file: tmp, entries: map[string]*tarEntry{}}
+	if err := tfs.extract(tar.NewReader(gr)); err != nil {
+		tfs.Close()
+		return nil, fmt.Errorf("read %s: %w", location, err)
+	}
+
+	names := make([]string, 0, len(tfs.entries))
+	for name := range tfs.entries {
+		names = append(names, name)
+	}
+	root, err := dataRoot(names)
+	if err != nil {
+		tfs.Close()
+		return nil, err
+	}
+	sub, err := fs.Sub(tfs, root)
+	if err != nil {
+		tfs.Close()
+		return nil, err
+	}
+	return subCloser{FS: sub, Closer: tfs}, nil
+}
+
+// A read-only file system of the files extracted from a tar file.
+type tarFS struct {
+	// Concatenated contents of all regular files.
+	file *os.File
+	// Maps the path of each file and directory to its entry.
+	entries map[string]*tarEntry
+}
+
+// A file or directory within a tarFS.
+type tarEntry struct {
+	name    string
+	offset  int64
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	// Names of children, if the entry is a directory.
+	children []string
+}
+
+// Reads the regular files of tr into the file system.
+func (t *tarFS) extract(tr *tar.Reader) error {
+	t.entries["."] = &tarEntry{name: ".", mode: fs.ModeDir | 0555}
+	var offset int64
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Clean(h.Name), "/")
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			t.dir(name).modTime = h.ModTime
+		case tar.TypeReg:
+			n, err := io.Copy(t.file, tr)
+			if err != nil {
+				return err
+			}
+			t.dir(path.Dir(name)).addChild(path.Base(name))
+			t.entries[name] = &tarEntry{
+				name:    path.Base(name),
+				offset:  offset,
+				size:    n,
+				mode:    h.FileInfo().Mode().Perm(),
+				modTime: h.ModTime,
+			}
+			offset += n
+		}
+	}
+	return nil
+}
+
+// Returns the directory entry of name, creating it and its parents as needed.
+func (t *tarFS) dir(name string) *tarEntry {
+	if e, ok := t.entries[name]; ok {
+		return e
+	}
+	e := &tarEntry{name: path.Base(name), mode: fs.ModeDir | 0555}
+	t.entries[name] = e
+	t.dir(path.Dir(name)).addChild(e.name)
+	return e
+}
+
+// Adds a child to a directory entry.
+func (e *tarEntry) addChild(name string) {
+	if !slices.Contains(e.children, name) {
+		e.children = append(e.children, name)
+	}
+}
+
+// Closes and removes the temporary file.
+func (t *tarFS) Close() error {
+	err := t.file.Close()
+	if rerr := os.Remove(t.file.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.mode.IsDir() {
+		return &tarDir{fsys: t, path: name, entry: e}, nil
+	}
+	return &tarFile{entry: e, r: io.NewSectionReader(t.file, e.offset, e.size)}, nil
+}
+
+func (e *tarEntry) Name() string               { return e.name }
+func (e *tarEntry) Size() int64                { return e.size }
+func (e *tarEntry) Mode() fs.FileMode          { return e.mode }
+func (e *tarEntry) ModTime() time.Time         { return e.modTime }
+func (e *tarEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *tarEntry) Sys() any                   { return nil }
+func (e *tarEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *tarEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+// An open regular file of a tarFS.
+type tarFile struct {
+	entry *tarEntry
+	r     *io.SectionReader
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *tarFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *tarFile) Close() error               { return nil }
+
+// An open directory of a tarFS.
+type tarDir struct {
+	fsys  *tarFS
+	path  string
+	entry *tarEntry
+	// Number of children already read.
+	n int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *tarDir) Close() error               { return nil }
+
+func (d *tarDir) Read(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *tarDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	children := slices.Sorted(slices.Values(d.entry.children))[d.n:]
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+		children = children[:min(count, len(children))]
+	}
+	entries := make([]fs.DirEntry, len(children))
+	for i, name := range children {
+		entries[i] = d.fsys.entries[path.Join(d.path, name)]
+	}
+	d.n += len(entries)
+	return entries, nil
+}
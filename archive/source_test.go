@@ -0,0 +1,189 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Returns the files of a build archive with a single build, with the data
+// directory placed under prefix. A decoy groups.json is placed deeper than the
+// data directory.
+func testArchiveFiles(prefix string) map[string]string {
+	return map[string]string{
+		prefix + "groups.json":                              `["Player"]`,
+		prefix + "Player/metadata.json":                     `{"Files":["API-Dump.json"],"Builds":[{"GUID":"version-0001","Date":"2024-01-01T00:00:00Z","Version":"0.600.0.1"}]}`,
+		prefix + "Player/builds/version-0001/API-Dump.json": testDump,
+		prefix + "Player/builds/other/groups.json":          `["Decoy"]`,
+	}
+}
+
+// Returns the names of files in sorted order.
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Writes files as a zip file at path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range sortedNames(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Writes files as a gzip-compressed tar file at path. Only the immediate
+// directory of each file is given an entry, so that other directories are
+// implied by the paths of files.
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dirs := map[string]bool{}
+	for _, name := range sortedNames(files) {
+		if dir := filepath.ToSlash(filepath.Dir(name)); !dirs[dir] {
+			dirs[dir] = true
+			tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, ModTime: date})
+		}
+		content := files[name]
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  date,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveSources(t *testing.T) {
+	// Temporary files of tar.gz sources are created here.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "archive.zip")
+	writeZip(t, zipPath, testArchiveFiles("build-archive-master/data/"))
+	tgzPath := filepath.Join(dir, "archive.tgz")
+	writeTarGz(t, tgzPath, testArchiveFiles("./data/"))
+
+	tests := []struct {
+		name     string
+		location string
+	}{
+		{name: "ZipScheme", location: "zip:" + filepath.ToSlash(zipPath)},
+		{name: "ZipExtension", location: zipPath},
+		{name: "TarGzScheme", location: "tar.gz:" + filepath.ToSlash(tgzPath)},
+		{name: "TarGzExtension", location: tgzPath},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys, err := OpenSource(test.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fsys.(io.Closer).Close()
+			err = fstest.TestFS(fsys,
+				"groups.json",
+				"Player/metadata.json",
+				"Player/builds/version-0001/API-Dump.json",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo, err := OpenRepo(test.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if groups := repo.Groups(); !slices.Equal(groups, []string{"Player"}) {
+				t.Errorf("expected groups [Player], got %v", groups)
+			}
+			builds := repo.Builds()
+			if len(builds) != 1 {
+				t.Fatalf("expected 1 build, got %d", len(builds))
+			}
+			rc, err := repo.Open(builds[0], "API-Dump.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil || string(b) != testDump {
+				t.Errorf("expected dump content, got %q (%v)", b, err)
+			}
+			for _, problem := range repo.Verify(VerifyOptions{}) {
+				t.Errorf("verify: %s", problem)
+			}
+			if err := repo.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("expected temporary files to be removed, found %d", len(entries))
+	}
+}
+
+func TestDataRoot(t *testing.T) {
+	tests := []struct {
+		names []string
+		root  string
+	}{
+		{names: []string{"groups.json", "Player/metadata.json"}, root: "."},
+		{names: []string{"repo/data/Player/metadata.json", "repo/data/groups.json"}, root: "repo/data"},
+		{names: []string{"/a/b/groups.json", "/a/groups.json"}, root: "a"},
+		{names: []string{"./data/groups.json"}, root: "data"},
+	}
+	for _, test := range tests {
+		root, err := dataRoot(test.names)
+		if err != nil {
+			t.Errorf("%v: %s", test.names, err)
+		} else if root != test.root {
+			t.Errorf("%v: expected root %q, got %q", test.names, test.root, root)
+		}
+	}
+	if _, err := dataRoot([]string{"data/metadata.json"}); err == nil {
+		t.Error("expected error without groups.json")
+	}
+}
@@ -0,0 +1,215 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"strings"
+
+	rbxdumpjson "github.com/robloxapi/rbxdump/json"
+)
+
+// The kind of a Problem.
+type ProblemKind int
+
+const (
+	// A file listed by the metadata of a group is not present for a build.
+	ProblemMissingFile ProblemKind = iota
+	// A file declared missing for a build is present.
+	ProblemUnexpectedFile
+	// The GUID of a build is used by another build.
+	ProblemDuplicateGUID
+	// The date of a build is earlier than that of the preceding build of the
+	// group.
+	ProblemDateOrder
+	// A file could not be opened, read, or decoded.
+	ProblemDecode
+	// The content of a file does not match its checksum.
+	ProblemChecksum
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case ProblemMissingFile:
+		return "missing file"
+	case ProblemUnexpectedFile:
+		return "unexpected file"
+	case ProblemDuplicateGUID:
+		return "duplicate GUID"
+	case ProblemDateOrder:
+		return "date order"
+	case ProblemDecode:
+		return "decode"
+	case ProblemChecksum:
+		return "checksum"
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(k))
+}
+
+// A problem with the integrity of a repo, as found by Repo.Verify.
+type Problem struct {
+	Kind  ProblemKind
+	Build Build
+	// The name of the file with the problem, if any.
+	File string
+	// Describes the problem.
+	Err error
+}
+
+func (p Problem) String() string {
+	var s strings.Builder
+	s.WriteString(p.Build.Group)
+	s.WriteString("/")
+	s.WriteString(p.Build.GUID)
+	if p.File != "" {
+		s.WriteString("/")
+		s.WriteString(p.File)
+	}
+	fmt.Fprintf(&s, ": %s: %s", p.Kind, p.Err)
+	return s.String()
+}
+
+// Options for Repo.Verify.
+type VerifyOptions struct {
+	// If true, then only the presence of files is checked. The content of files
+	// is not decoded or compared against checksums.
+	Quick bool
+	// If non-nil, called for each build before it is verified.
+	Progress func(build Build)
+}
+
+// Decoders used to verify the content of files by name.
+var decoders = map[string]func(r io.Reader) error{
+	"API-Dump.json": func(r io.Reader) error {
+		_, err := rbxdumpjson.Decode(r)
+		return err
+	},
+	"Full-API-Dump.json": func(r io.Reader) error {
+		_, err := rbxdumpjson.Decode(r)
+		return err
+	},
+}
+
+// Checks the integrity of the repo, returning a list of problems found. The
+// following are verified:
+//
+//   - Each file listed by a group is present for each build of the group,
+//     unless the build declares the file as missing.
+//   - Each file declared missing by a build is not present.
+//   - No two builds have the same GUID.
+//   - The builds of each group are ordered by date.
+//   - Each API dump can be decoded.
+//   - The content of each file matches its checksum, if the metadata of the
+//     group supplies one.
+func (r *Repo) Verify(opts VerifyOptions) (problems []Problem) {
+	if r.d == nil {
+		return nil
+	}
+	guids := map[string]Build{}
+	for _, group := range r.d.groups {
+		md := r.d.metadata[group]
+		var prev Build
+		for i, build := range md.Builds {
+			if opts.Progress != nil {
+				opts.Progress(build)
+			}
+			if other, ok := guids[build.GUID]; ok {
+				problems = append(problems, Problem{
+					Kind:  ProblemDuplicateGUID,
+					Build: build,
+					Err:   fmt.Errorf("also used by build of %s dated %s", other.Group, other.Date.Format("2006-01-02")),
+				})
+			} else {
+				guids[build.GUID] = build
+			}
+			if i > 0 && build.Date.Before(prev.Date) {
+				problems = append(problems, Problem{
+					Kind:  ProblemDateOrder,
+					Build: build,
+					Err:   fmt.Errorf("dated %s, before preceding build %s dated %s", build.Date.Format("2006-01-02"), prev.GUID, prev.Date.Format("2006-01-02")),
+				})
+			}
+			prev = build
+
+			for _, name := range md.Files {
+				if p, ok := r.verifyFile(md, build, name, opts); !ok {
+					problems = append(problems, p)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// Verifies a single file of a build. Returns false with the problem if the file
+// is not valid.
+func (r *Repo) verifyFile(md metadata, build Build, name string, opts VerifyOptions) (p Problem, ok bool) {
+	p = Problem{Build: build, File: name}
+	_, err := fs.Stat(r.fs, filePath(build, name))
+	present := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		p.Kind = ProblemDecode
+		p.Err = err
+		return p, false
+	}
+	if !r.Exists(build, name) {
+		if present {
+			p.Kind = ProblemUnexpectedFile
+			p.Err = errors.New("declared missing, but present")
+			return p, false
+		}
+		return p, true
+	}
+	if !present {
+		p.Kind = ProblemMissingFile
+		p.Err = errors.New("listed, but not present")
+		return p, false
+	}
+	if opts.Quick {
+		return p, true
+	}
+
+	checksum := md.Checksums[build.GUID][name]
+	decode := decoders[name]
+	if checksum == "" && decode == nil {
+		return p, true
+	}
+	f, err := r.fs.Open(filePath(build, name))
+	if err != nil {
+		p.Kind = ProblemDecode
+		p.Err = err
+		return p, false
+	}
+	defer f.Close()
+	var rd io.Reader = f
+	var h hash.Hash
+	if checksum != "" {
+		h = sha256.New()
+		rd = io.TeeReader(rd, h)
+	}
+	if decode != nil {
+		if err := decode(rd); err != nil {
+			p.Kind = ProblemDecode
+			p.Err = err
+			return p, false
+		}
+	}
+	if h != nil {
+		// Consume any content not read by the decoder.
+		if _, err := io.Copy(io.Discard, rd); err != nil {
+			p.Kind = ProblemDecode
+			p.Err = err
+			return p, false
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+			p.Kind = ProblemChecksum
+			p.Err = fmt.Errorf("expected SHA-256 %s, got %s", checksum, sum)
+			return p, false
+		}
+	}
+	return p, true
+}
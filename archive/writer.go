@@ -0,0 +1,215 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Writes builds to a build archive in a directory of the local file system.
+// Changes to metadata are written by Flush.
+type Writer struct {
+	// If true, then the SHA-256 digest of each written file is recorded in the
+	// metadata of its group.
+	Checksums bool
+
+	dir    string
+	groups []string
+	// Maps the name of each group to its metadata and files.
+	metadata map[string]*groupWriter
+}
+
+// The state of a group being written.
+type groupWriter struct {
+	md metadata
+	// Maps the GUID of each build to the names of files present for the
+	// build.
+	present map[string]map[string]bool
+}
+
+// Returns a Writer that writes to the build archive at dir, which is the data
+// directory of the archive. If the directory contains an existing archive, then
+// its builds are retained.
+func NewWriter(dir string) (*Writer, error) {
+	w := &Writer{dir: dir, metadata: map[string]*groupWriter{}}
+	if _, err := os.Stat(filepath.Join(dir, "groups.json")); errors.Is(err, fs.ErrNotExist) {
+		return w, nil
+	}
+	repo, err := NewRepo(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range repo.d.groups {
+		md := repo.d.metadata[group]
+		g := &groupWriter{md: md, present: map[string]map[string]bool{}}
+		for _, build := range md.Builds {
+			present := map[string]bool{}
+			for _, name := range md.Files {
+				if repo.Exists(build, name) {
+					present[name] = true
+				}
+			}
+			g.present[build.GUID] = present
+		}
+		w.groups = append(w.groups, group)
+		w.metadata[group] = g
+	}
+	return w, nil
+}
+
+// Returns an error if name cannot be used as a single path element.
+func validName(kind, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	}
+	return nil
+}
+
+// Adds a group to the archive. Does nothing if the group already exists.
+func (w *Writer) AddGroup(group string) error {
+	if err := validName("group", group); err != nil {
+		return err
+	}
+	if _, ok := w.metadata[group]; ok {
+		return nil
+	}
+	w.groups = append(w.groups, group)
+	w.metadata[group] = &groupWriter{present: map[string]map[string]bool{}}
+	return nil
+}
+
+// Adds a build to the group of the build. The group must have been added, and
+// must not already contain a build with the same GUID. The files of the build
+// are written with WriteFile.
+func (w *Writer) AddBuild(build Build) error {
+	g, ok := w.metadata[build.Group]
+	if !ok {
+		return fmt.Errorf("unknown group %q", build.Group)
+	}
+	if err := validName("GUID", build.GUID); err != nil {
+		return err
+	}
+	if _, ok := g.present[build.GUID]; ok {
+		return fmt.Errorf("group %s already has build %s", build.Group, build.GUID)
+	}
+	g.md.Builds = append(g.md.Builds, build)
+	g.present[build.GUID] = map[string]bool{}
+	return nil
+}
+
+// Writes the content of r as the file of a build. The build must have been
+// added. The name of the file is added to the files listed by the group.
+// Builds of the group without the file are declared as missing it. If
+// w.Checksums is false, then any recorded checksum of the file is removed.
+func (w *Writer) WriteFile(build Build, name string, r io.Reader) error {
+	g, ok := w.metadata[build.Group]
+	if !ok {
+		return fmt.Errorf("unknown group %q", build.Group)
+	}
+	present, ok := g.present[build.GUID]
+	if !ok {
+		return fmt.Errorf("group %s has no build %s", build.Group, build.GUID)
+	}
+	if err := validName("file", name); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if w.Checksums {
+		r = io.TeeReader(r, h)
+	}
+	if err := writeFile(filepath.Join(w.dir, filepath.FromSlash(filePath(build, name))), r); err != nil {
+		return err
+	}
+	present[name] = true
+	if w.Checksums {
+		if g.md.Checksums == nil {
+			g.md.Checksums = map[string]map[string]string{}
+		}
+		if g.md.Checksums[build.GUID] == nil {
+			g.md.Checksums[build.GUID] = map[string]string{}
+		}
+		g.md.Checksums[build.GUID][name] = hex.EncodeToString(h.Sum(nil))
+	} else if sums := g.md.Checksums[build.GUID]; sums != nil {
+		// Any existing checksum is of the previous content.
+		delete(sums, name)
+		if len(sums) == 0 {
+			delete(g.md.Checksums, build.GUID)
+		}
+	}
+	return nil
+}
+
+// Writes groups.json and the metadata.json of each group. Groups are sorted by
+// name, the builds of each group are sorted by date, and listed files are
+// sorted by name.
+func (w *Writer) Flush() error {
+	slices.Sort(w.groups)
+	for _, group := range w.groups {
+		g := w.metadata[group]
+		md := metadata{Checksums: g.md.Checksums}
+
+		files := map[string]bool{}
+		for _, name := range g.md.Files {
+			files[name] = true
+		}
+		for _, present := range g.present {
+			maps.Copy(files, present)
+		}
+		md.Files = slices.Sorted(maps.Keys(files))
+
+		md.Builds = make([]Build, len(g.md.Builds))
+		for i, build := range g.md.Builds {
+			build.Group = ""
+			md.Builds[i] = build
+		}
+		slices.SortStableFunc(md.Builds, func(a, b Build) int {
+			return a.Date.Compare(b.Date)
+		})
+
+		for _, build := range md.Builds {
+			var missing []string
+			for _, name := range md.Files {
+				if !g.present[build.GUID][name] {
+					missing = append(missing, name)
+				}
+			}
+			if missing != nil {
+				if md.Missing == nil {
+					md.Missing = map[string][]string{}
+				}
+				md.Missing[build.GUID] = missing
+			}
+		}
+		g.md.Files = md.Files
+
+		if err := w.writeJSON(filepath.Join(group, "metadata.json"), md); err != nil {
+			return err
+		}
+	}
+	if w.groups == nil {
+		return w.writeJSON("groups.json", []string{})
+	}
+	return w.writeJSON("groups.json", w.groups)
+}
+
+// Encodes value as JSON to the file at name within the archive.
+func (w *Writer) writeJSON(name string, value any) error {
+	var b bytes.Buffer
+	je := json.NewEncoder(&b)
+	je.SetEscapeHTML(false)
+	je.SetIndent("", "\t")
+	if err := je.Encode(value); err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	return writeFile(filepath.Join(w.dir, name), &b)
+}
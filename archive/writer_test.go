@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Content of a valid API dump.
+const testDump = `{"Version":1,"Classes":[],"Enums":[]}`
+
+// Decodes the JSON file at name within dir into v.
+func readJSON(t *testing.T, dir, name string, v any) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("decode %s: %s", name, err)
+	}
+}
+
+// Fails if the archive at dir has any problems.
+func verifyArchive(t *testing.T, dir string) *Repo {
+	t.Helper()
+	repo, err := NewRepo(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range repo.Verify(VerifyOptions{}) {
+		t.Errorf("verify: %s", problem)
+	}
+	return repo
+}
+
+func TestWriter(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Checksums = true
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	b1 := Build{Group: "Player", GUID: "version-0001", Date: day(1)}
+	b2 := Build{Group: "Player", GUID: "version-0002", Date: day(2)}
+	s1 := Build{Group: "Studio", GUID: "version-0003", Date: day(3)}
+
+	for _, group := range []string{"Studio", "Player"} {
+		if err := w.AddGroup(group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Builds are added out of order.
+	for _, build := range []Build{b2, b1, s1} {
+		if err := w.AddBuild(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.AddBuild(b1); err == nil {
+		t.Error("expected error adding duplicate build")
+	}
+	if err := w.AddBuild(Build{Group: "Other", GUID: "version-0004"}); err == nil {
+		t.Error("expected error adding build to unknown group")
+	}
+	if err := w.AddBuild(Build{Group: "Player", GUID: "../version"}); err == nil {
+		t.Error("expected error adding build with invalid GUID")
+	}
+
+	files := []struct {
+		build   Build
+		name    string
+		content string
+	}{
+		{b1, "API-Dump.json", testDump},
+		{b1, "Extra.txt", "extra"},
+		{b2, "API-Dump.json", testDump},
+		{s1, "API-Dump.json", testDump},
+	}
+	for _, file := range files {
+		if err := w.WriteFile(file.build, file.name, strings.NewReader(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var groups []string
+	readJSON(t, dir, "groups.json", &groups)
+	if !slices.Equal(groups, []string{"Player", "Studio"}) {
+		t.Errorf("expected sorted groups, got %v", groups)
+	}
+	var md metadata
+	readJSON(t, dir, "Player/metadata.json", &md)
+	if !slices.Equal(md.Files, []string{"API-Dump.json", "Extra.txt"}) {
+		t.Errorf("expected sorted files, got %v", md.Files)
+	}
+	var guids []string
+	for _, build := range md.Builds {
+		guids = append(guids, build.GUID)
+	}
+	if !slices.Equal(guids, []string{b1.GUID, b2.GUID}) {
+		t.Errorf("expected builds sorted by date, got %v", guids)
+	}
+	if missing := map[string][]string{b2.GUID: {"Extra.txt"}}; !reflect.DeepEqual(md.Missing, missing) {
+		t.Errorf("expected missing %v, got %v", missing, md.Missing)
+	}
+	if len(md.Checksums[b1.GUID]) != 2 || len(md.Checksums[b2.GUID]) != 1 {
+		t.Errorf("unexpected checksums %v", md.Checksums)
+	}
+
+	repo := verifyArchive(t, dir)
+	if builds := repo.Builds(); len(builds) != 3 || builds[2].GUID != s1.GUID || builds[2].Group != s1.Group {
+		t.Errorf("unexpected builds %v", builds)
+	}
+	if repo.Exists(b2, "Extra.txt") || !repo.Exists(b1, "Extra.txt") {
+		t.Error("unexpected presence of Extra.txt")
+	}
+
+	// Builds of an existing archive are retained.
+	if w, err = NewWriter(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddBuild(b1); err == nil {
+		t.Error("expected error adding build of existing archive")
+	}
+	// Writing the missing file reconciles Missing, and rewriting a file
+	// without checksums removes its stale checksum.
+	if err := w.WriteFile(b2, "Extra.txt", strings.NewReader("extra")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile(b1, "Extra.txt", strings.NewReader("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	md = metadata{}
+	readJSON(t, dir, "Player/metadata.json", &md)
+	if len(md.Missing) != 0 {
+		t.Errorf("expected no missing files, got %v", md.Missing)
+	}
+	if _, ok := md.Checksums[b1.GUID]["Extra.txt"]; ok {
+		t.Error("expected stale checksum to be removed")
+	}
+	verifyArchive(t, dir)
+}
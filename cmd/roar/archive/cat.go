@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/anaminus/snek"
+)
+
+var catDef = snek.Def{
+	Name: "cat",
+	Doc: snek.Doc{
+		Summary:     "Write the content of a file of a build.",
+		Arguments:   "[flags] <build> <file>",
+		Description: catUsage,
+	},
+	New: func() snek.Command { return &CatCommand{} },
+}
+
+type CatCommand struct {
+	Source
+}
+
+func (c *CatCommand) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 2 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+	repo, err := c.Source.Open()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	found, err := repo.Find(opt.Arg(0))
+	if err != nil {
+		return err
+	}
+	build := found
+	name := opt.Arg(1)
+	rc, err := repo.Open(build, name)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	if rc == nil {
+		return fmt.Errorf("%s: file not found in %s", name, build.GUID)
+	}
+	defer rc.Close()
+	_, err = io.Copy(opt.Stdout, rc)
+	return err
+}
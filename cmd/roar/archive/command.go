@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/roar/archive"
+)
+
+var Def = snek.Def{
+	Name: "archive",
+	Doc: snek.Doc{
+		Summary:     "Inspect a build archive.",
+		Arguments:   "<command> [flags] [args]",
+		Description: usage,
+	},
+	New: func() snek.Command { return &Command{} },
+}
+
+// Subcommands of the archive command.
+var commands = []snek.Def{
+	listDef,
+	showDef,
+	catDef,
+	verifyDef,
+}
+
+type Command struct{}
+
+func (c *Command) Run(opt snek.Options) error {
+	program := snek.NewProgram(opt.Program+" "+opt.Def.Name, nil)
+	program.Arguments = opt.Arguments
+	program.Stdin = opt.Stdin
+	program.Stdout = opt.Stdout
+	program.Stderr = opt.Stderr
+	program.NoHelp()
+	for _, def := range commands {
+		program.Register(def)
+	}
+
+	name, input := program.Prepare()
+	if name == "" {
+		if len(opt.Arguments) > 0 {
+			fmt.Fprintln(opt.Stderr, snek.UnknownCommand{Name: opt.Arguments[0]}.Error())
+		}
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+	err := program.RunWithInput(name, input)
+	if err == flag.ErrHelp {
+		program.WriteUsageOf(opt.Stderr, program.Get(name))
+		return nil
+	}
+	return err
+}
+
+// Flags for locating the build archive, shared by each subcommand.
+type Source struct {
+	Source      string
+	SourceCache string
+}
+
+func (s *Source) SetFlags(flagset snek.FlagSet) {
+	flagset.StringVar(&s.Source, "source", "", "Location of builds.")
+	flagset.StringVar(&s.SourceCache, "source-cache", "", "Directory in which to cache files of a remote source.")
+}
+
+// Opens the repo at the source location.
+func (s *Source) Open() (*archive.Repo, error) {
+	if s.Source == "" {
+		return nil, fmt.Errorf("no source specified")
+	}
+	if s.SourceCache != "" {
+		archive.RegisterSource("http", archive.CachedHTTPSource(s.SourceCache))
+		archive.RegisterSource("https", archive.CachedHTTPSource(s.SourceCache))
+	}
+	repo, err := archive.OpenRepo(s.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo: %w", err)
+	}
+	return repo, nil
+}
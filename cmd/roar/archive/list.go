@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/rbxver"
+	"github.com/robloxapi/roar/archive"
+)
+
+var listDef = snek.Def{
+	Name: "list",
+	Doc: snek.Doc{
+		Summary:     "List builds of a build archive.",
+		Arguments:   "[flags]",
+		Description: listUsage,
+	},
+	New: func() snek.Command { return &ListCommand{} },
+}
+
+type ListCommand struct {
+	Source
+	Group   string
+	Since   string
+	Until   string
+	Version string
+}
+
+func (c *ListCommand) SetFlags(flagset snek.FlagSet) {
+	c.Source.SetFlags(flagset)
+	flagset.StringVar(&c.Group, "group", "", "List only builds of the given group.")
+	flagset.StringVar(&c.Since, "since", "", "List only builds dated on or after the given date.")
+	flagset.StringVar(&c.Until, "until", "", "List only builds dated on or before the given date.")
+	flagset.StringVar(&c.Version, "version", "", "List only builds whose version has the given prefix.")
+}
+
+// Parses a date of the form YYYY-MM-DD. Returns the zero time if s is empty.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// Returns whether version matches a version prefix. The prefix matches whole
+// components, so "0.61" does not match "0.612.0.6120532".
+func matchVersion(version rbxver.Version, prefix string) bool {
+	version.Format = rbxver.Dot
+	v := version.String()
+	return v == prefix || strings.HasPrefix(v, strings.TrimSuffix(prefix, ".")+".")
+}
+
+func (c *ListCommand) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	since, err := parseDate(c.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseDate(c.Until)
+	if err != nil {
+		return err
+	}
+	repo, err := c.Source.Open()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	w := tabwriter.NewWriter(opt.Stdout, 0, 0, 2, ' ', 0)
+	for _, build := range repo.Builds() {
+		if c.Group != "" && build.Group != c.Group {
+			continue
+		}
+		if !since.IsZero() && build.Date.Before(since) {
+			continue
+		}
+		// Include the entire day.
+		if !until.IsZero() && !build.Date.Before(until.AddDate(0, 0, 1)) {
+			continue
+		}
+		if c.Version != "" && !matchVersion(build.Version, c.Version) {
+			continue
+		}
+		writeBuild(w, build)
+	}
+	return w.Flush()
+}
+
+// Writes a line describing a build.
+func writeBuild(w *tabwriter.Writer, build archive.Build) {
+	build.Version.Format = rbxver.Dot
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		build.Date.Format(time.DateTime),
+		build.Version,
+		build.GUID,
+		build.Group,
+	)
+}
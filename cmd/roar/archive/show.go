@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/roar/archive"
+)
+
+var showDef = snek.Def{
+	Name: "show",
+	Doc: snek.Doc{
+		Summary:     "Display the files of a build.",
+		Arguments:   "[flags] <build>",
+		Description: showUsage,
+	},
+	New: func() snek.Command { return &ShowCommand{} },
+}
+
+type ShowCommand struct {
+	Source
+}
+
+// Returns the builds in repo with the given GUID.
+func findBuilds(repo *archive.Repo, guid string) (builds []archive.Build) {
+	for _, build := range repo.Builds() {
+		if build.GUID == guid {
+			builds = append(builds, build)
+		}
+	}
+	return builds
+}
+
+func (c *ShowCommand) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 1 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+	repo, err := c.Source.Open()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	found, err := repo.Find(opt.Arg(0))
+	if err != nil {
+		return err
+	}
+	builds := findBuilds(repo, found.GUID)
+	w := tabwriter.NewWriter(opt.Stdout, 0, 0, 2, ' ', 0)
+	for i, build := range builds {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeBuild(w, build)
+		for _, name := range repo.Files(build) {
+			if !repo.Exists(build, name) {
+				fmt.Fprintf(w, "\t%s\tmissing\n", name)
+				continue
+			}
+			info := repo.Stat(build, name)
+			if info == nil {
+				fmt.Fprintf(w, "\t%s\tnot found\n", name)
+				continue
+			}
+			fmt.Fprintf(w, "\t%s\t%d\n", name, info.Size())
+		}
+	}
+	return w.Flush()
+}
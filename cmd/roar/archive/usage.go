@@ -0,0 +1,109 @@
+package archive
+
+const usage = `
+Inspects a build archive. The first argument is one of the following commands:
+
+    list      List builds.
+    show      Display the files of a build.
+    cat       Write the content of a file of a build.
+    verify    Check the integrity of a build archive.
+
+Run a command with -help to display its usage. Each command accepts the
+following flags:
+
+--source string
+
+    The data source, which is expected to comply with the structure specified by
+    build-archive:
+
+        https://github.com/RobloxAPI/build-archive
+
+    The source is located in the same way as for the generate command. It may be
+    a directory, a zip or tar.gz file, or an HTTP URL.
+
+--source-cache string
+
+    A directory in which files read from an HTTP source are cached between runs.
+    If unspecified, then files are not cached.
+
+`
+
+const verifyUsage = `
+Checks the integrity of a build archive against its metadata. Each problem found
+is printed on a line, prefixed by the group and GUID of the build, and the name
+of the file, if any. The following problems are reported:
+
+    missing file       A file listed by the group is not present for a build.
+    unexpected file    A file declared missing by a build is present.
+    duplicate GUID     The GUID of a build is used by another build.
+    date order         A build is dated before the preceding build of the group.
+    decode             A file could not be read, or an API dump could not be
+                       decoded.
+    checksum           The content of a file does not match the checksum
+                       supplied by the metadata of the group.
+
+Checksums are optional. When present, they are read from the Checksums field of
+a group's metadata.json, which maps the GUID of a build to a map of file names
+to hex-encoded SHA-256 digests.
+
+--quick
+
+    If specified, then only the presence of files is checked. The content of
+    files is not decoded or compared against checksums.
+
+--verbose
+
+    If specified, then each build is printed as it is verified.
+
+`
+
+const listUsage = `
+Lists the builds of a build archive, ordered by date. Each build is printed on a
+line with its date, version, GUID, and group.
+
+--group string
+
+    If specified, then only builds of the given group are listed.
+
+--since string
+
+    If specified, then only builds dated on or after the given date are listed.
+    The date has the form YYYY-MM-DD.
+
+--until string
+
+    If specified, then only builds dated on or before the given date are listed.
+    The date has the form YYYY-MM-DD.
+
+--version string
+
+    If specified, then only builds whose version begins with the given
+    components are listed. For example, "0.612" matches "0.612.0.6120532", but
+    not "0.61.0.6100000".
+
+`
+
+const showUsage = `
+Displays a build. The date, version, GUID, and group of the build is printed,
+followed by each file listed by the group. Each file is displayed with its size,
+or "missing" if the build declares that the file is missing. If several builds
+have the GUID of the build, then each is displayed.
+` + buildUsage + `
+
+`
+
+const catUsage = `
+Writes the content of a file of a build to standard output.
+` + buildUsage + `
+
+`
+
+const buildUsage = `
+The build is identified by one of the following:
+
+    version-0123456789abcdef    A GUID, or a unique prefix of a GUID.
+    0.612.0.6120532             A version number.
+    2024-02-15                  The latest build at or before a date.
+    latest                      The latest build.
+    previous                    The build before the latest build.
+`
@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"fmt"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/roar/archive"
+)
+
+var verifyDef = snek.Def{
+	Name: "verify",
+	Doc: snek.Doc{
+		Summary:     "Check the integrity of a build archive.",
+		Arguments:   "[flags]",
+		Description: verifyUsage,
+	},
+	New: func() snek.Command { return &VerifyCommand{} },
+}
+
+type VerifyCommand struct {
+	Source
+	Quick   bool
+	Verbose bool
+}
+
+func (c *VerifyCommand) SetFlags(flagset snek.FlagSet) {
+	c.Source.SetFlags(flagset)
+	flagset.BoolVar(&c.Quick, "quick", false, "Check only the presence of files.")
+	flagset.BoolVar(&c.Verbose, "verbose", false, "Print each build as it is verified.")
+}
+
+func (c *VerifyCommand) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	repo, err := c.Source.Open()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	opts := archive.VerifyOptions{Quick: c.Quick}
+	if c.Verbose {
+		opts.Progress = func(build archive.Build) {
+			fmt.Fprintln(opt.Stderr, "verifying", build)
+		}
+	}
+	problems := repo.Verify(opts)
+	for _, p := range problems {
+		fmt.Fprintln(opt.Stdout, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+	return nil
+}
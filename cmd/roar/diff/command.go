@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/rbxdump"
+	"github.com/robloxapi/rbxdump/diff"
+	rbxdumpjson "github.com/robloxapi/rbxdump/json"
+	"github.com/robloxapi/rbxver"
+	"github.com/robloxapi/roar/archive"
+	"github.com/robloxapi/roar/history"
+)
+
+const (
+	apiDump     = "API-Dump.json"
+	fullAPIDump = "Full-API-Dump.json"
+)
+
+var Def = snek.Def{
+	Name: "diff",
+	Doc: snek.Doc{
+		Summary:     "Compare two API dumps.",
+		Arguments:   "[flags] <a> <b>",
+		Description: usage,
+	},
+	New: func() snek.Command { return &Command{} },
+}
+
+type Command struct {
+	Source      string
+	SourceCache string
+	Format      string
+
+	repo *archive.Repo
+}
+
+func (c *Command) SetFlags(flagset snek.FlagSet) {
+	flagset.StringVar(&c.Source, "source", "", "Location of builds.")
+	flagset.StringVar(&c.SourceCache, "source-cache", "", "Directory in which to cache files of a remote source.")
+	flagset.StringVar(&c.Format, "format", "text", "Output format (text, json, markdown).")
+}
+
+func (c *Command) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 2 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+
+	format, ok := formats[c.Format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", c.Format)
+	}
+
+	defer func() {
+		if c.repo != nil {
+			c.repo.Close()
+		}
+	}()
+	prev, prevLabel, err := c.loadDump(opt.Arg(0))
+	if err != nil {
+		return err
+	}
+	next, nextLabel, err := c.loadDump(opt.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	actions := diff.Diff{Prev: prev, Next: next, SeparateFields: true}.Diff()
+	history.SortActions(actions)
+	inverse := diff.Patch{Root: prev}.Inverse(actions)
+	changes := make([]Change, len(actions))
+	for i, action := range actions {
+		changes[i] = Change{Action: action}
+		if action.Type == diff.Change {
+			changes[i].Prev = inverse[i].Fields
+		}
+	}
+
+	return format(opt.Stdout, prevLabel, nextLabel, changes)
+}
+
+// Loads a dump identified by arg. arg may be a path to a local dump file, or a
+// build GUID or version number resolved through the source repository. Returns
+// a label describing the dump.
+func (c *Command) loadDump(arg string) (dump *rbxdump.Root, label string, err error) {
+	if f, err := os.Open(arg); err == nil {
+		defer f.Close()
+		if dump, err = rbxdumpjson.Decode(f); err != nil {
+			return nil, "", fmt.Errorf("decode %s: %w", arg, err)
+		}
+		return dump, arg, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+
+	if c.Source == "" {
+		return nil, "", fmt.Errorf("%s: file not found, and no source specified", arg)
+	}
+	if c.repo == nil {
+		if c.SourceCache != "" {
+			archive.RegisterSource("http", archive.CachedHTTPSource(c.SourceCache))
+			archive.RegisterSource("https", archive.CachedHTTPSource(c.SourceCache))
+		}
+		if c.repo, err = archive.OpenRepo(c.Source); err != nil {
+			return nil, "", fmt.Errorf("failed to read repo: %w", err)
+		}
+	}
+
+	build, err := c.repo.Find(arg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rc io.ReadCloser
+	switch {
+	case c.repo.Exists(build, fullAPIDump):
+		rc, err = c.repo.Open(build, fullAPIDump)
+	case c.repo.Exists(build, apiDump):
+		rc, err = c.repo.Open(build, apiDump)
+	default:
+		return nil, "", fmt.Errorf("%s: build has no API dump", build.GUID)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("open %s: %w", build.GUID, err)
+	}
+	defer rc.Close()
+	if dump, err = rbxdumpjson.Decode(rc); err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", build.GUID, err)
+	}
+	build.Version.Format = rbxver.Dot
+	return dump, fmt.Sprintf("%s (%s)", build.Version, build.GUID), nil
+}
@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/robloxapi/rbxdump"
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/roar/history"
+)
+
+// Represents an action along with the values prior to the action.
+type Change struct {
+	// The change that occurred.
+	Action diff.Action
+	// The previous values of a Change action.
+	Prev rbxdump.Fields `json:",omitempty"`
+}
+
+// Writes a list of changes between dumps labeled prev and next to w.
+type format func(w io.Writer, prev, next string, changes []Change) error
+
+var formats = map[string]format{
+	"text":     formatText,
+	"json":     formatJSON,
+	"markdown": formatMarkdown,
+}
+
+// Returns the name of the field of a Change action. An action is expected to
+// have only one field.
+func changedField(action diff.Action) string {
+	if action.Type != diff.Change {
+		return ""
+	}
+	for name := range action.Fields {
+		return name
+	}
+	return ""
+}
+
+// Returns the identifier of the entity to which action applies.
+func entityName(action diff.Action) string {
+	if action.Secondary != "" {
+		return action.Primary + "." + action.Secondary
+	}
+	return action.Primary
+}
+
+// Writes each change as a line of text.
+func formatText(w io.Writer, prev, next string, changes []Change) error {
+	for _, change := range changes {
+		action := change.Action
+		if field := changedField(action); field != "" {
+			_, err := fmt.Fprintf(w, "%s %s of %s %s from %s to %s\n",
+				action.Type,
+				field,
+				action.Element,
+				entityName(action),
+				history.FormatValue(change.Prev[field]),
+				history.FormatValue(action.Fields[field]),
+			)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", action.Type, action.Element, entityName(action)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes changes as a JSON array.
+func formatJSON(w io.Writer, prev, next string, changes []Change) error {
+	je := json.NewEncoder(w)
+	je.SetEscapeHTML(false)
+	je.SetIndent("", "\t")
+	return je.Encode(changes)
+}
+
+// Writes changes as a Markdown changelog.
+func formatMarkdown(w io.Writer, prev, next string, changes []Change) error {
+	if _, err := fmt.Fprintf(w, "## %s → %s\n\n", prev, next); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+	for _, change := range changes {
+		action := change.Action
+		if field := changedField(action); field != "" {
+			_, err := fmt.Fprintf(w, "- %s %s of %s `%s` from `%s` to `%s`\n",
+				action.Type,
+				field,
+				action.Element,
+				entityName(action),
+				history.FormatValue(change.Prev[field]),
+				history.FormatValue(action.Fields[field]),
+			)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "- %s %s `%s`\n", action.Type, action.Element, entityName(action)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
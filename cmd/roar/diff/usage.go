@@ -0,0 +1,53 @@
+package diff
+
+const usage = `
+Compares two API dumps, printing the differences between them.
+
+Each argument may be a path to a local API dump file, or a build identified by
+one of the following:
+
+    version-0123456789abcdef    A GUID, or a unique prefix of a GUID.
+    0.612.0.6120532             A version number.
+    2024-02-15                  The latest build at or before a date.
+    latest                      The latest build.
+    previous                    The build before the latest build.
+
+Builds are resolved through the source, and require the --source flag.
+
+Differences are sorted in the same order as the history database.
+
+The following flags can be specified:
+
+--source string
+
+    The data source, which is expected to comply with the structure specified by
+    build-archive:
+
+        https://github.com/RobloxAPI/build-archive
+
+    The source can be either a file path or a URL. A file path reads from the
+    local file system. A path ending in .zip, .tar.gz, or .tgz reads from an
+    archive file containing the data directory at any depth. A URL is read
+    according to its scheme:
+
+    - file: A directory of the local file system.
+    - http, https: A remote source via HTTP.
+    - zip: A zip file, such as zip:build-archive.zip.
+    - tar.gz: A gzip-compressed tar file, such as tar.gz:build-archive.tar.gz.
+
+--source-cache string
+
+    A directory in which files read from an HTTP source are cached between runs.
+    The files of a build are kept indefinitely, while other files are
+    revalidated with the server each time they are read. If unspecified, then
+    files are not cached.
+
+--format string
+
+    The output format. Must be one of the following:
+
+    - text: One line per change.
+    - json: A JSON array of changes, each with an Action and Prev field.
+    - markdown: A Markdown changelog.
+
+`
@@ -0,0 +1,79 @@
+package dump
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anaminus/snek"
+	rbxdumpjson "github.com/robloxapi/rbxdump/json"
+	"github.com/robloxapi/roar/cmd/roar/generate"
+	"github.com/robloxapi/roar/history"
+)
+
+var Def = snek.Def{
+	Name: "dump",
+	Doc: snek.Doc{
+		Summary:     "Reconstruct the API dump at an update.",
+		Arguments:   "[flags]",
+		Description: usage,
+	},
+	New: func() snek.Command { return &Command{} },
+}
+
+type Command struct {
+	Site        string
+	Config      string
+	At          string
+	Checkpoints string
+}
+
+func (c *Command) SetFlags(flagset snek.FlagSet) {
+	flagset.StringVar(&c.Site, "site", "", "Location of Hugo site.")
+	flagset.StringVar(&c.Config, "config", "", "Config file of the generate command.")
+	flagset.StringVar(&c.At, "at", "latest", "The update at which to reconstruct the dump.")
+	flagset.StringVar(&c.Checkpoints, "checkpoints", "", "Directory of dumps kept by generate.")
+}
+
+func (c *Command) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 0 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+
+	site, output, err := generate.ReadOutputConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	if c.Site == "" {
+		c.Site = site
+	}
+	histPath := filepath.Join(c.Site, generate.SiteData, output.History)
+	if _, err := os.Stat(histPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("history not found at %s", histPath)
+	}
+	hist, err := generate.ReadHistory(histPath)
+	if err != nil {
+		return err
+	}
+
+	update, err := hist.Find(c.At)
+	if err != nil {
+		return err
+	}
+
+	cursor := history.Cursor{}
+	if c.Checkpoints != "" {
+		if cursor.Checkpoints, err = history.OpenCheckpoints(c.Checkpoints, hist); err != nil {
+			return fmt.Errorf("open checkpoints: %w", err)
+		}
+	}
+	if !cursor.Roll(update) {
+		return fmt.Errorf("failed to roll history to %s", update.GUID)
+	}
+	return rbxdumpjson.Encode(opt.Stdout, cursor.Dump)
+}
@@ -0,0 +1,48 @@
+package dump
+
+const usage = `
+Writes to standard output the full API dump as it existed at a single update,
+as recorded by the history database of a site generated with the generate
+command. The dump is reconstructed from the history alone; the source of builds
+is not required.
+
+The dump is written in the format of the API dump JSON files of the build
+archive. Unlike the Dump.json data file of a site, which contains every entity
+that has ever existed, the dump contains only the entities present at the
+update.
+
+The following flags can be specified:
+
+--site string
+
+    The path to the Hugo site from which history data will be read. The history
+    database is expected to be located at data/History.json, unless renamed by
+    the config file.
+
+--config string
+
+    The path to a config file of the generate command. The site and the names
+    of output files are read from the file. The --site flag overrides the site
+    of the file.
+
+--at string
+
+    The update at which to reconstruct the dump. The update is identified by one
+    of the following:
+
+        version-0123456789abcdef    A GUID, or a unique prefix of a GUID.
+        0.612.0.6120532             A version number.
+        2024-02-15                  The latest update at or before a date.
+        latest                      The latest update.
+        previous                    The update before the latest update.
+
+    Defaults to latest.
+
+--checkpoints string
+
+    A directory of checkpoints kept by the generate command. If specified, then
+    the dump is reconstructed from the nearest checkpoint preceding the update,
+    rather than from the earliest update. Checkpoints that were kept for a
+    different history are ignored.
+
+`
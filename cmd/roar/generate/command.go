@@ -3,23 +3,19 @@ package generate
 import (
 	"encoding/json"
 	"fmt"
-	"io/fs"
-	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/pprof"
-	"strings"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/anaminus/snek"
-	"github.com/publysher/httpfs"
-	"github.com/robloxapi/rbxdump"
-	"github.com/robloxapi/rbxdump/diff"
 	"github.com/robloxapi/roar/archive"
 	"github.com/robloxapi/roar/docs"
 	"github.com/robloxapi/roar/history"
 	"github.com/robloxapi/roar/icons"
 	"github.com/robloxapi/roar/index"
+	"github.com/robloxapi/roar/reflect"
 	"github.com/robloxapi/roar/search"
 )
 
@@ -27,19 +23,26 @@ const (
 	apiDump     = "API-Dump.json"
 	fullAPIDump = "Full-API-Dump.json"
 
+	reflectionMetadata = "ReflectionMetadata.xml"
+
 	jsonIndent = "\t"
 
-	siteAssets  = "assets"
 	siteContent = "content"
-	siteData    = "data"
-
-	manifestData = "manifest.json"
-	historyData  = "History.json"
-	indexData    = "Index.json"
-	docsData     = "Docs.json"
-	dumpData     = "Dump.json"
-	reflectData  = "Reflect.json"
-	searchDB     = "search.db"
+
+	manifestData       = "manifest.json"
+	historyData        = "History.json"
+	reflectHistoryData = "ReflectHistory.json"
+	indexData          = "Index.json"
+	docsData           = "Docs.json"
+	dumpData           = "Dump.json"
+	reflectData        = "Reflect.json"
+	searchDB           = "search.db"
+)
+
+// Directories of the site in which output files are written.
+const (
+	SiteAssets = "assets" // Search database.
+	SiteData   = "data"   // Data files.
 )
 
 var Def = snek.Def{
@@ -49,27 +52,61 @@ var Def = snek.Def{
 		Arguments:   "[flags] [config]",
 		Description: usage,
 	},
-	New: func() snek.Command { return &Command{} },
+	New: func() snek.Command { return &Command{Output: DefaultOutput} },
 }
 
 type Command struct {
-	Site       string
-	Source     string
-	Docs       string
-	Update     bool
-	NoCache    bool
-	Disable    Disable
-	CPUProfile string
+	Site          string  `yaml:"site"`
+	Source        string  `yaml:"source"`
+	SourceCache   string  `yaml:"source-cache"`
+	Docs          string  `yaml:"docs"`
+	Update        bool    `yaml:"update"`
+	NoCache       bool    `yaml:"no-cache"`
+	Jobs          int     `yaml:"jobs"`
+	Repair        bool    `yaml:"repair"`
+	Strict        bool    `yaml:"strict"`
+	Full          bool    `yaml:"full"`
+	Checkpoints   string  `yaml:"checkpoints"`
+	Interval      int     `yaml:"checkpoint-interval"`
+	CompactSearch bool    `yaml:"compact-search"`
+	Disable       Disable `yaml:"disable"`
+	Output        Output  `yaml:"output"`
+	CPUProfile    string  `yaml:"cpuprofile"`
 }
 
 type Disable struct {
-	Index   bool // Don't generate index data.
-	History bool // Don't write history data (cache).
-	Dump    bool // Don't generate dump data.
-	Reflect bool // Don't generate reflection metadata.
-	Pages   bool // Don't generate website pages.
-	Icons   bool // Don't generate icon resources.
-	Docs    bool // Don't generate documentation data.
+	Index      bool `yaml:"index"`       // Don't generate index data.
+	History    bool `yaml:"history"`     // Don't write history data (cache).
+	Dump       bool `yaml:"dump"`        // Don't generate dump data.
+	Reflect    bool `yaml:"reflect"`     // Don't generate reflection metadata.
+	Pages      bool `yaml:"pages"`       // Don't generate website pages.
+	Icons      bool `yaml:"icons"`       // Don't generate icon resources.
+	Docs       bool `yaml:"docs"`        // Don't generate documentation data.
+	SearchDocs bool `yaml:"search-docs"` // Don't index documentation for searching.
+}
+
+// Names of files written by the command. Data files are written under the data
+// directory of the site, and the search database is written under the assets
+// directory.
+type Output struct {
+	History        string `yaml:"history"`
+	ReflectHistory string `yaml:"reflect-history"`
+	Index          string `yaml:"index"`
+	Docs           string `yaml:"docs"`
+	Dump           string `yaml:"dump"`
+	Reflect        string `yaml:"reflect"`
+	Search         string `yaml:"search"`
+}
+
+// Default names of output files.
+var DefaultOutput = Output{
+	History:        historyData,
+	ReflectHistory: reflectHistoryData,
+	Index:          indexData,
+	Docs:           docsData,
+	Dump:           dumpData,
+	Reflect:        reflectData,
+	Search:         searchDB,
 }
 
 func (c *Command) SetFlags(flagset snek.FlagSet) {
@@ -77,9 +114,17 @@ func (c *Command) SetFlags(flagset snek.FlagSet) {
 
 	flagset.StringVar(&c.Site, "site", "", "Location of Hugo site.")
 	flagset.StringVar(&c.Source, "source", "", "Location of builds.")
+	flagset.StringVar(&c.SourceCache, "source-cache", "", "Directory in which to cache files of a remote source.")
 	flagset.StringVar(&c.Docs, "docs", "", "Location of documentation.")
 	flagset.BoolVar(&c.Update, "update", false, "Update history database.")
 	flagset.BoolVar(&c.NoCache, "no-cache", false, "Ignore cached history.")
+	flagset.IntVar(&c.Jobs, "jobs", runtime.NumCPU(), "Number of builds to fetch concurrently.")
+	flagset.BoolVar(&c.Repair, "repair", false, "Rebuild history that diverges from cached history.")
+	flagset.BoolVar(&c.Strict, "strict", false, "Fail when a build cannot be added to history.")
+	flagset.BoolVar(&c.Full, "full", false, "Recompute every update of cached history.")
+	flagset.StringVar(&c.Checkpoints, "checkpoints", "", "Directory in which to keep dumps of history.")
+	flagset.IntVar(&c.Interval, "checkpoint-interval", 100, "Number of updates between each checkpoint.")
+	flagset.BoolVar(&c.CompactSearch, "compact-search", false, "Encode the search database in the compact format.")
 
 	flagset.BoolVar(&c.Disable.Index, "disable-index", false, "Don't generate index data.")
 	flagset.BoolVar(&c.Disable.History, "disable-history", false, "Don't write history data (cache).")
@@ -87,6 +132,8 @@ func (c *Command) SetFlags(flagset snek.FlagSet) {
 	flagset.BoolVar(&c.Disable.Reflect, "disable-reflect", false, "Don't generate reflection metadata.")
 	flagset.BoolVar(&c.Disable.Pages, "disable-pages", false, "Don't generate website pages.")
 	flagset.BoolVar(&c.Disable.Icons, "disable-icons", false, "Don't generate website icons.")
+	flagset.BoolVar(&c.Disable.Docs, "disable-docs", false, "Don't generate documentation data.")
+	flagset.BoolVar(&c.Disable.SearchDocs, "disable-search-docs", false, "Don't index documentation for searching.")
 }
 
 func (c *Command) Run(opt snek.Options) error {
@@ -94,6 +141,26 @@ func (c *Command) Run(opt snek.Options) error {
 		return err
 	}
 
+	// Read config file, if given. Flags are parsed again so that they override
+	// values from the file. Parsing stops at the config path, so flags following
+	// it are parsed separately.
+	config := opt.Arg(0)
+	if config != "" {
+		rest := opt.Args()[1:]
+		if err := c.ReadConfig(config); err != nil {
+			return err
+		}
+		if err := opt.Parse(opt.Arguments); err != nil {
+			return err
+		}
+		if err := opt.Parse(rest); err != nil {
+			return err
+		}
+		if opt.NArg() > 0 {
+			return fmt.Errorf("unexpected argument %q", opt.Arg(0))
+		}
+	}
+
 	if c.CPUProfile != "" {
 		f, err := os.Create(c.CPUProfile)
 		if err != nil {
@@ -107,17 +174,14 @@ func (c *Command) Run(opt snek.Options) error {
 	}
 
 	if c.Source == "" {
-		if opt.Arg(0) == "" {
+		if config == "" {
 			opt.WriteUsageOf(opt.Stderr, opt.Def)
 			return nil
 		}
 		return fmt.Errorf("source option is required")
 	}
-	if !strings.HasSuffix(c.Source, "/") {
-		c.Source += "/"
-	}
 
-	manifestPath := filepath.Join(c.Site, siteData, manifestData)
+	manifestPath := filepath.Join(c.Site, SiteData, manifestData)
 	manifest, err := ReadManifest(manifestPath)
 	if err != nil && err != ErrSchemaMismatch {
 		return err
@@ -132,91 +196,146 @@ func (c *Command) Run(opt snek.Options) error {
 	}
 
 	// Read history file, if available.
-	histPath := filepath.Join(c.Site, siteData, historyData)
+	histPath := filepath.Join(c.Site, SiteData, c.Output.History)
+	reflectHistPath := filepath.Join(c.Site, SiteData, c.Output.ReflectHistory)
 	var storedHist *history.Root
+	var storedReflectHist *reflect.History
 	// If there's a schema mismatch, then force a fresh start.
 	if c.NoCache || err == ErrSchemaMismatch {
 		storedHist = history.NewRoot()
+		storedReflectHist = reflect.NewHistory()
 	} else {
 		var err error
 		if storedHist, err = ReadHistory(histPath); err != nil {
 			return err
 		}
+		if storedReflectHist, err = ReadReflectHistory(reflectHistPath); err != nil {
+			return err
+		}
 	}
 
 	// Create archive repository.
-	sourceURL, _ := url.Parse(c.Source)
-	var source fs.FS
-	if sourceURL.Scheme == "http" || sourceURL.Scheme == "https" {
-		source = httpfs.NewFS(sourceURL)
-	} else {
-		source = os.DirFS(c.Source)
+	if c.SourceCache != "" {
+		archive.RegisterSource("http", archive.CachedHTTPSource(c.SourceCache))
+		archive.RegisterSource("https", archive.CachedHTTPSource(c.SourceCache))
 	}
-	repo, err := archive.NewRepo(source)
+	repo, err := archive.OpenRepo(c.Source)
 	if err != nil {
 		return fmt.Errorf("failed to read repo: %w", err)
 	}
+	defer repo.Close()
 
 	var updatedHist *history.Root
 	if c.Update {
 		// Produce updated history using stored history as cache.
 		fmt.Println("rebuilding history database")
-		updatedHist = MergeHistory(repo, storedHist)
+		var checkpoints *history.Checkpoints
+		if c.Checkpoints != "" {
+			if checkpoints, err = history.OpenCheckpoints(c.Checkpoints, storedHist); err != nil {
+				return fmt.Errorf("open checkpoints: %w", err)
+			}
+		}
+
+		var report MergeReport
+		updatedHist, err = MergeHistory(repo, storedHist, MergeOptions{
+			Jobs:   c.Jobs,
+			Repair: c.Repair,
+			Strict: c.Strict,
+			Report: &report,
+			// Repairing requires every stored update to be recomputed.
+			Incremental: !c.Full && !c.Repair,
+			Checkpoints: checkpoints,
+		})
+		if err != nil {
+			return err
+		}
 
 		// Normalize tags within history.
 		NormalizeHistoryTags(updatedHist)
 
 		// Write new history file.
 		if !c.Disable.History {
-			if err := WriteFile(c.Site, historyData, updatedHist); err != nil {
+			if err := WriteFile(c.Site, c.Output.History, updatedHist); err != nil {
+				return err
+			}
+			if checkpoints != nil {
+				if err := checkpoints.Update(updatedHist, c.Interval); err != nil {
+					return fmt.Errorf("update checkpoints: %w", err)
+				}
+			}
+		}
+
+		// Produce updated reflection metadata history.
+		if !c.Disable.Reflect {
+			fmt.Println("rebuilding reflection metadata history")
+			updatedReflectHist, err := MergeReflectHistory(repo, storedReflectHist, MergeOptions{
+				Repair: c.Repair,
+				Strict: c.Strict,
+				Report: &report,
+			})
+			if err != nil {
 				return err
 			}
+			if !c.Disable.History {
+				if err := WriteFile(c.Site, c.Output.ReflectHistory, updatedReflectHist); err != nil {
+					return err
+				}
+			}
 		}
+		report.WriteSummary(os.Stdout)
 	} else {
 		updatedHist = storedHist
 	}
 
 	// Generate dump by rolling through entire history, excluding actions that
 	// remove entities.
-	patcher := diff.Patch{Root: &rbxdump.Root{}}
-	for _, update := range updatedHist.Update {
-		for _, change := range update.Changes {
-			if change.Action.Type == diff.Remove {
-				continue
-			}
-			patcher.Patch([]diff.Action{change.Action})
-		}
-	}
+	dump := PatchDump(updatedHist)
 	if !c.Disable.Dump {
-		if err := WriteFile(c.Site, dumpData, patcher.Root); err != nil {
+		if err := WriteFile(c.Site, c.Output.Dump, dump); err != nil {
 			return err
 		}
 	}
 
 	// Generate index file.
 	indexRoot := &index.Root{}
-	if err := indexRoot.Build(updatedHist, patcher.Root); err != nil {
+	if err := indexRoot.Build(updatedHist, dump); err != nil {
 		return err
 	}
 	if !c.Disable.Index {
-		if err := WriteFile(c.Site, indexData, indexRoot); err != nil {
+		if err := WriteFile(c.Site, c.Output.Index, indexRoot); err != nil {
 			return err
 		}
 	}
 
 	// Generate documentation.
 	if !c.Disable.Docs && c.Docs != "" {
-		if err := docs.Write(filepath.Join(c.Site, siteData, docsData), c.Docs); err != nil {
+		if err := docs.Write(filepath.Join(c.Site, SiteData, c.Output.Docs), c.Docs); err != nil {
 			return err
 		}
 	}
 
+	// Generate reflection metadata from the latest build that has it.
+	if !c.Disable.Reflect {
+		if build, ok := LatestReflectBuild(repo); ok {
+			fmt.Println("reading reflection metadata from", build.GUID)
+			reflectRoot, err := ReadReflect(repo, build)
+			if err != nil {
+				return err
+			}
+			if err := WriteFile(c.Site, c.Output.Reflect, reflectRoot); err != nil {
+				return err
+			}
+		} else {
+			fmt.Println("no reflection metadata found")
+		}
+	}
+
 	// Generate syntax highlighting CSS files.
-	os.MkdirAll(filepath.Join(c.Site, siteAssets, "css/highlight"), 0755)
-	if err := writeSCSS(filepath.Join(c.Site, siteAssets, "css/highlight/light.scss"), "highlight-light", docs.Light); err != nil {
+	os.MkdirAll(filepath.Join(c.Site, SiteAssets, "css/highlight"), 0755)
+	if err := writeSCSS(filepath.Join(c.Site, SiteAssets, "css/highlight/light.scss"), "highlight-light", docs.Light); err != nil {
 		return err
 	}
-	if err := writeSCSS(filepath.Join(c.Site, siteAssets, "css/highlight/dark.scss"), "highlight-dark", docs.Dark); err != nil {
+	if err := writeSCSS(filepath.Join(c.Site, SiteAssets, "css/highlight/dark.scss"), "highlight-dark", docs.Dark); err != nil {
 		return err
 	}
 
@@ -227,13 +346,24 @@ func (c *Command) Run(opt snek.Options) error {
 
 	// Generate icons.
 	if !c.Disable.Icons {
-		if err := icons.Write(filepath.Join(c.Site, siteAssets)); err != nil {
+		if err := icons.Write(filepath.Join(c.Site, SiteAssets)); err != nil {
 			return err
 		}
 	}
 
-	// Generate search database.
-	if err := search.WriteDB(filepath.Join(c.Site, siteAssets, searchDB), indexRoot, patcher.Root); err != nil {
+	// Generate search database, indexing history, and documentation if
+	// available.
+	var docsRoot *docs.Root
+	if !c.Disable.SearchDocs {
+		if docsRoot, err = ReadDocs(filepath.Join(c.Site, SiteData, c.Output.Docs)); err != nil {
+			return err
+		}
+	}
+	format := search.FormatFixed
+	if c.CompactSearch {
+		format = search.FormatCompact
+	}
+	if err := search.WriteDB(filepath.Join(c.Site, SiteAssets, c.Output.Search), indexRoot, dump, updatedHist, docsRoot, format); err != nil {
 		return err
 	}
 
@@ -263,7 +393,7 @@ func writeSCSS(path, name string, style *chroma.Style) error {
 
 // Writes JSON to file at path.
 func WriteFile(site, file string, value any) error {
-	path := filepath.Join(site, siteData, file)
+	path := filepath.Join(site, SiteData, file)
 	os.MkdirAll(filepath.Dir(path), 0755)
 	f, err := os.Create(path)
 	if err != nil {
@@ -0,0 +1,39 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reads a YAML config file at path into c. Fields not present in the file are
+// left unchanged. Unknown fields produce an error.
+func (c *Command) ReadConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+	yd := yaml.NewDecoder(f)
+	yd.KnownFields(true)
+	if err := yd.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Reads the site location and output names from the YAML config file at path,
+// as used by the generate command. Returns the default output names if path is
+// empty.
+func ReadOutputConfig(path string) (site string, output Output, err error) {
+	c := Command{Output: DefaultOutput}
+	if path != "" {
+		if err := c.ReadConfig(path); err != nil {
+			return "", Output{}, err
+		}
+	}
+	return c.Site, c.Output, nil
+}
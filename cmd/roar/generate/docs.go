@@ -0,0 +1,28 @@
+package generate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/robloxapi/roar/docs"
+)
+
+// Reads documentation data from docsPath. Returns nil if the file does not
+// exist.
+func ReadDocs(docsPath string) (*docs.Root, error) {
+	f, err := os.Open(docsPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open %s: %w", docsData, err)
+	}
+	defer f.Close()
+	var root docs.Root
+	if err := json.NewDecoder(f).Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", docsData, err)
+	}
+	return &root, nil
+}
@@ -1,14 +1,12 @@
 package generate
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"slices"
-	"sort"
 	"strings"
 	"unicode"
 
@@ -42,8 +40,296 @@ func FilterBuilds(repo *archive.Repo, builds []archive.Build) []archive.Build {
 	return builds
 }
 
-// Retrieves all builds from repo.
-func MergeHistory(repo *archive.Repo, storedHist *history.Root) *history.Root {
+// The kind of a BuildProblem.
+type BuildProblemKind int
+
+const (
+	// The build has no API dump.
+	ProblemNoAPI BuildProblemKind = iota
+	// The API dump of the build could not be opened or decoded.
+	ProblemDecode
+	// The stored history could not be rolled to the update of the build.
+	ProblemRoll
+	// The reflection metadata of the build could not be opened or parsed.
+	ProblemReflect
+)
+
+func (k BuildProblemKind) String() string {
+	switch k {
+	case ProblemNoAPI:
+		return "no api"
+	case ProblemDecode:
+		return "bad api"
+	case ProblemRoll:
+		return "bad history"
+	case ProblemReflect:
+		return "bad reflection metadata"
+	}
+	return fmt.Sprintf("BuildProblemKind(%d)", int(k))
+}
+
+// A problem with a single build encountered while merging history.
+type BuildProblem struct {
+	Kind  BuildProblemKind
+	Build archive.Build
+	Err   error
+}
+
+func (p *BuildProblem) Error() string {
+	return fmt.Sprintf("%s %s: %s", p.Kind, p.Build.GUID, p.Err)
+}
+
+func (p *BuildProblem) Unwrap() error {
+	return p.Err
+}
+
+// Problems encountered while merging history.
+type MergeReport struct {
+	Problems []*BuildProblem
+}
+
+// Writes a summary of the report to w.
+func (r *MergeReport) WriteSummary(w io.Writer) error {
+	if len(r.Problems) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "encountered problems with %d builds:\n", len(r.Problems)); err != nil {
+		return err
+	}
+	for _, p := range r.Problems {
+		if _, err := fmt.Fprintf(w, "\t%s\n", p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The result of fetching the API dump of a build.
+type fetchedDump struct {
+	dump    *rbxdump.Root
+	problem *BuildProblem
+}
+
+// Fetches and decodes the API dump of a build from repo. Returns a problem if
+// the build has no valid API dump.
+func fetchDump(repo *archive.Repo, build archive.Build) fetchedDump {
+	problem := func(kind BuildProblemKind, err error) fetchedDump {
+		return fetchedDump{problem: &BuildProblem{Kind: kind, Build: build, Err: err}}
+	}
+	var rc io.ReadCloser
+	var err error
+	switch {
+	case repo.Exists(build, fullAPIDump):
+		rc, err = repo.Open(build, fullAPIDump)
+	case repo.Exists(build, apiDump):
+		rc, err = repo.Open(build, apiDump)
+	default:
+		return problem(ProblemNoAPI, errors.New("build has no API dump"))
+	}
+	if err != nil {
+		return problem(ProblemDecode, err)
+	}
+	if rc == nil {
+		return problem(ProblemDecode, errors.New("API dump not found"))
+	}
+	dump, err := rbxdumpjson.Decode(rc)
+	rc.Close()
+	if err != nil {
+		return problem(ProblemDecode, err)
+	}
+	return fetchedDump{dump: dump}
+}
+
+// Fetches the API dumps of builds concurrently, using at most jobs workers.
+// Results are received from the returned channel in the same order as builds.
+// Builds for which skip returns true are not fetched, and produce an empty
+// result. Fetching stops early when done is closed.
+func prefetchDumps(repo *archive.Repo, builds []archive.Build, jobs int, skip func(archive.Build) bool, done <-chan struct{}) <-chan chan fetchedDump {
+	jobs = max(jobs, 1)
+	// Limits the number of results that have been fetched but not yet
+	// consumed, so that dumps are not held in memory far ahead of the consumer.
+	results := make(chan chan fetchedDump, jobs)
+	sem := make(chan struct{}, jobs)
+	go func() {
+		defer close(results)
+		for _, build := range builds {
+			result := make(chan fetchedDump, 1)
+			select {
+			case results <- result:
+			case <-done:
+				return
+			}
+			if skip(build) {
+				result <- fetchedDump{}
+				continue
+			}
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem }()
+				result <- fetchDump(repo, build)
+			}()
+		}
+	}()
+	return results
+}
+
+// Indicates that the actions of an update recomputed from the history cache
+// differ from the actions stored in the cache. This usually occurs when the
+// behavior of the differ has changed since the cache was generated.
+type DivergenceError struct {
+	// The GUID of the divergent update.
+	GUID string
+	// The actions stored in the cache.
+	Expected []diff.Action
+	// The recomputed actions.
+	Actual []diff.Action
+}
+
+// Returns the actions that appear only in Expected, and the actions that
+// appear only in Actual, each in their original order. Actions are compared by
+// their string representation.
+func (err *DivergenceError) Diff() (removed, added []diff.Action) {
+	counts := map[string]int{}
+	for _, action := range err.Actual {
+		counts[action.String()]++
+	}
+	for _, action := range err.Expected {
+		if s := action.String(); counts[s] > 0 {
+			counts[s]--
+		} else {
+			removed = append(removed, action)
+		}
+	}
+	for _, action := range err.Actual {
+		if s := action.String(); counts[s] > 0 {
+			counts[s]--
+			added = append(added, action)
+		}
+	}
+	return removed, added
+}
+
+func (err *DivergenceError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "update %s diverges from cached history: expected %d actions, got %d",
+		err.GUID,
+		len(err.Expected),
+		len(err.Actual),
+	)
+	removed, added := err.Diff()
+	for _, action := range removed {
+		fmt.Fprintf(&b, "\n\t- %s", action)
+	}
+	for _, action := range added {
+		fmt.Fprintf(&b, "\n\t+ %s", action)
+	}
+	return b.String()
+}
+
+// Returns a DivergenceError if actions differ from the changes of update.
+// Returns nil otherwise.
+func checkDivergence(update *history.Update, actions []diff.Action) error {
+	err := &DivergenceError{GUID: update.GUID, Actual: actions}
+	err.Expected = make([]diff.Action, len(update.Changes))
+	for i, change := range update.Changes {
+		err.Expected[i] = change.Action
+	}
+	if removed, added := err.Diff(); len(removed) == 0 && len(added) == 0 {
+		return nil
+	}
+	return err
+}
+
+// Options for MergeHistory.
+type MergeOptions struct {
+	// Number of API dumps fetched and decoded concurrently.
+	Jobs int
+	// If true, then when an update diverges from the stored history, the
+	// history is rebuilt from the divergent update onward by fetching builds
+	// from the repo, instead of returning a DivergenceError.
+	Repair bool
+	// If true, then the first problem with a build is returned as an error.
+	// Otherwise, builds without a valid API dump are skipped, and the history
+	// is rebuilt from the repo when the stored history cannot be rolled.
+	Strict bool
+	// If non-nil, receives each problem with a build.
+	Report *MergeReport
+	// If true, then builds newer than the latest stored update are appended to
+	// the stored history, which is returned. Only the latest stored update is
+	// recomputed and checked for divergence; earlier updates are not verified.
+	// If there are no new builds, then the stored history is returned without
+	// being verified. Has no effect if the stored history is empty, or if a new
+	// build is older than the latest stored update, in which case the entire
+	// history is rebuilt.
+	Incremental bool
+	// If non-nil, used to reconstruct the dump of the latest stored update when
+	// appending builds.
+	Checkpoints *history.Checkpoints
+}
+
+// Records a problem to opts.Report. In strict mode, the problem is returned as
+// an error.
+func (opts MergeOptions) report(problem *BuildProblem) error {
+	if opts.Report != nil {
+		opts.Report.Problems = append(opts.Report.Problems, problem)
+	}
+	if opts.Strict {
+		return problem
+	}
+	fmt.Println(problem)
+	return nil
+}
+
+// Returns the builds that are not in the stored history, which must not be
+// empty. Returns false if any such build is not newer than the latest stored
+// update, in which case the builds cannot be appended.
+func appendableBuilds(storedHist *history.Root, builds []archive.Build, storedUpdates map[string]*history.Update) (newBuilds []archive.Build, ok bool) {
+	latest := storedHist.Update[len(storedHist.Update)-1]
+	for _, build := range builds {
+		if _, ok := storedUpdates[build.GUID]; ok {
+			continue
+		}
+		if !build.Date.After(latest.Date) {
+			return nil, false
+		}
+		newBuilds = append(newBuilds, build)
+	}
+	return newBuilds, true
+}
+
+// Recomputes the actions of the latest update of hist from its build, which is
+// looked up within builds, and the dump of the preceding update, which is
+// reconstructed with cursor. Returns a DivergenceError if the actions differ
+// from the stored changes of the update, or a BuildProblem if the build cannot
+// be fetched. Does nothing if the build is not within builds.
+func checkLatest(repo *archive.Repo, hist *history.Root, builds []archive.Build, cursor *history.Cursor) error {
+	latest := hist.Update[len(hist.Update)-1]
+	i := slices.IndexFunc(builds, func(build archive.Build) bool {
+		return build.GUID == latest.GUID
+	})
+	if i < 0 {
+		fmt.Printf("cannot verify %s: build not found\n", latest.GUID)
+		return nil
+	}
+	fetched := fetchDump(repo, builds[i])
+	if fetched.problem != nil {
+		return fetched.problem
+	}
+	differ := diff.Diff{Next: fetched.dump, SeparateFields: true}
+	if latest.Prev != nil {
+		if !cursor.Roll(latest.Prev) {
+			return fmt.Errorf("failed to roll cursor to update %s", latest.Prev.GUID)
+		}
+		differ.Prev = cursor.Dump
+	}
+	actions := differ.Diff()
+	history.SortActions(actions)
+	return checkDivergence(latest, actions)
+}
+
+// Retrieves all builds from repo. Up to opts.Jobs API dumps are fetched and
+// decoded concurrently.
+func MergeHistory(repo *archive.Repo, storedHist *history.Root, opts MergeOptions) (*history.Root, error) {
 	// Map updates to GUID.
 	storedUpdates := make(map[string]*history.Update, len(storedHist.Update))
 	for _, update := range storedHist.Update {
@@ -64,95 +350,120 @@ func MergeHistory(repo *archive.Repo, storedHist *history.Root) *history.Root {
 		return false
 	})
 
+	report := opts.report
+
 	// Walk through each build. Compared to known builds to fetch new builds
 	// incrementally.
 	differ := diff.Diff{SeparateFields: true}
 	var cursor history.Cursor
 	updatedHist := history.NewRoot()
-	for _, build := range allBuilds {
+	if opts.Incremental && len(storedHist.Update) > 0 {
+		newBuilds, ok := appendableBuilds(storedHist, allBuilds, storedUpdates)
+		switch {
+		case !ok:
+			fmt.Println("cannot append builds; rebuilding history")
+		case len(newBuilds) == 0:
+			// Nothing to append, so the stored history is not verified.
+			fmt.Println("no new builds")
+			return storedHist, nil
+		default:
+			latest := storedHist.Update[len(storedHist.Update)-1]
+			cursor.Checkpoints = opts.Checkpoints
+			// Verify the overlap between the stored history and the repo.
+			if err := checkLatest(repo, storedHist, allBuilds, &cursor); err != nil {
+				var problem *BuildProblem
+				if !errors.As(err, &problem) {
+					return nil, err
+				}
+				if err := report(problem); err != nil {
+					return nil, err
+				}
+			}
+			if !cursor.Roll(latest) {
+				return nil, fmt.Errorf("failed to roll cursor to latest update %s", latest.GUID)
+			}
+			fmt.Println("appending", len(newBuilds), "builds to", latest.GUID)
+			differ.Prev = cursor.Dump
+			updatedHist = storedHist
+			allBuilds = newBuilds
+			storedUpdates = nil
+		}
+	}
+	done := make(chan struct{})
+	defer func() { close(done) }()
+	results := prefetchDumps(repo, allBuilds, opts.Jobs, func(build archive.Build) bool {
+		_, ok := storedUpdates[build.GUID]
+		return ok
+	}, done)
+	// Whether the stored history is being ignored.
+	repairing := false
+	// Fetches every build from repo starting at index i, rather than using the
+	// stored history.
+	repair := func(i int) {
+		fmt.Println("repairing history from", allBuilds[i].GUID)
+		repairing = true
+		close(done)
+		done = make(chan struct{})
+		results = prefetchDumps(repo, allBuilds[i:], opts.Jobs, func(archive.Build) bool {
+			return false
+		}, done)
+	}
+	for i := 0; i < len(allBuilds); i++ {
+		build := allBuilds[i]
+		result := <-results
 		var dump *rbxdump.Root
-		if update, ok := storedUpdates[build.GUID]; ok {
+		update, stored := storedUpdates[build.GUID]
+		if stored && !repairing {
 			// Get dump for stored history.
 			if !cursor.Roll(update) {
-				panic("failed to roll cursor!")
+				err := report(&BuildProblem{Kind: ProblemRoll, Build: build, Err: errors.New("failed to roll cursor")})
+				if err != nil {
+					return nil, err
+				}
+				// Retry the build by fetching it.
+				repair(i)
+				i--
+				continue
 			}
 			fmt.Println("rolled to", build)
 			dump = cursor.Dump
 		} else {
-			// Get dump by fetching from repo.
-			var rc io.ReadCloser
-			switch {
-			case repo.Exists(build, fullAPIDump):
-				fmt.Println("fetching", build)
-				rc, _ = repo.Open(build, fullAPIDump)
-			case repo.Exists(build, apiDump):
-				fmt.Println("fetching", build)
-				rc, _ = repo.Open(build, apiDump)
-			default:
-				fmt.Println("no api", build)
-				continue
-			}
-			if rc == nil {
-				fmt.Println("bad api", build.GUID)
-				continue
-			}
-
-			var err error
-			dump, err = rbxdumpjson.Decode(rc)
-			rc.Close()
-			if err != nil {
-				fmt.Printf("bad api %s: %s\n", build.GUID, err)
+			// Get dump fetched from repo.
+			fetched := <-result
+			if fetched.problem != nil {
+				if err := report(fetched.problem); err != nil {
+					return nil, err
+				}
 				continue
 			}
+			fmt.Println("fetching", build)
+			dump = fetched.dump
 		}
 
 		differ.Next = dump
 		actions := differ.Diff()
-		sort.Slice(actions, func(i, j int) bool {
-			if pi, pj := actions[i].Element.Primary(), actions[j].Element.Primary(); pi != pj {
-				return pi < pj
-			}
-			if actions[i].Primary != actions[j].Primary {
-				return actions[i].Primary < actions[j].Primary
-			}
-			if actions[i].Element != actions[j].Element {
-				return actions[i].Element < actions[j].Element
-			}
-			if actions[i].Secondary != actions[j].Secondary {
-				return actions[i].Secondary < actions[j].Secondary
-			}
-			if actions[i].Type != actions[j].Type {
-				return actions[i].Type > actions[j].Type
-			}
-			var fieldi, fieldj string
-			for field := range actions[i].Fields {
-				fieldi = field
-				break
-			}
-			for field := range actions[i].Fields {
-				fieldj = field
-				break
-			}
-			return fieldi < fieldj
-		})
-		updatedHist.AppendUpdate(build, actions, differ.Prev)
-		fmt.Printf("\tappended %d actions\n", len(actions))
+		history.SortActions(actions)
 
-		if update, ok := storedUpdates[build.GUID]; ok {
-			if len(actions) != len(update.Changes) {
-				var b bytes.Buffer
-				for _, v := range actions {
-					fmt.Fprintln(&b, v)
+		if stored && !repairing {
+			if err := checkDivergence(update, actions); err != nil {
+				if !opts.Repair {
+					return nil, err
 				}
-				fmt.Println("DIFF")
-				os.Exit(1)
+				fmt.Println(err)
+				// Retry the build by fetching it.
+				repair(i)
+				i--
+				continue
 			}
 		}
 
+		updatedHist.AppendUpdate(build, actions, differ.Prev)
+		fmt.Printf("\tappended %d actions\n", len(actions))
+
 		differ.Prev = differ.Next.Copy()
 	}
 
-	return updatedHist
+	return updatedHist, nil
 }
 
 // Reads history JSON from histPath.
@@ -175,6 +486,22 @@ func ReadHistory(histPath string) (storedhist *history.Root, err error) {
 	return storedHist, nil
 }
 
+// Generates a dump by rolling through the entire history of hist, excluding
+// actions that remove entities. The result contains every entity that has ever
+// existed, with the latest known fields of each.
+func PatchDump(hist *history.Root) *rbxdump.Root {
+	patcher := diff.Patch{Root: &rbxdump.Root{}}
+	for _, update := range hist.Update {
+		for _, change := range update.Changes {
+			if change.Action.Type == diff.Remove {
+				continue
+			}
+			patcher.Patch([]diff.Action{change.Action})
+		}
+	}
+	return patcher.Root
+}
+
 // Normalizes any tags and security context values within hist to their
 // canonical forms. For example, "WriteOnly" and "writeonly" are considered
 // equivalent tags, which are normalized by selecting which ever has the most
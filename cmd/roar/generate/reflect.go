@@ -0,0 +1,180 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/robloxapi/roar/archive"
+	"github.com/robloxapi/roar/reflect"
+)
+
+// Returns the latest build in repo that has a ReflectionMetadata file. Returns
+// false if no such build exists.
+func LatestReflectBuild(repo *archive.Repo) (build archive.Build, ok bool) {
+	builds := repo.Builds()
+	for i := len(builds) - 1; i >= 0; i-- {
+		if repo.Exists(builds[i], reflectionMetadata) {
+			return builds[i], true
+		}
+	}
+	return build, false
+}
+
+// Reads and parses the ReflectionMetadata file of build from repo.
+func ReadReflect(repo *archive.Repo, build archive.Build) (root reflect.Root, err error) {
+	rc, err := repo.Open(build, reflectionMetadata)
+	if err != nil {
+		return root, fmt.Errorf("open %s: %w", reflectionMetadata, err)
+	}
+	if rc == nil {
+		return root, fmt.Errorf("open %s: not found in %s", reflectionMetadata, build.GUID)
+	}
+	defer rc.Close()
+	if root, err = reflect.ParseReflectionMetadata(rc); err != nil {
+		return root, fmt.Errorf("parse %s: %w", reflectionMetadata, err)
+	}
+	return root, nil
+}
+
+// Indicates that the changes of a reflection metadata update recomputed from
+// the cache differ from the changes stored in the cache. This occurs when a
+// build has been inserted before or removed from between stored updates.
+type ReflectDivergenceError struct {
+	// The GUID of the divergent update.
+	GUID string
+	// The changes stored in the cache.
+	Expected []reflect.Change
+	// The recomputed changes.
+	Actual []reflect.Change
+}
+
+func (err *ReflectDivergenceError) Error() string {
+	return fmt.Sprintf("reflection update %s diverges from cached history: expected %d changes, got %d",
+		err.GUID,
+		len(err.Expected),
+		len(err.Actual),
+	)
+}
+
+// Returns a ReflectDivergenceError if changes differ from the changes of
+// update. Changes are compared by their JSON representation. Returns nil
+// otherwise.
+func checkReflectDivergence(update *reflect.Update, changes []reflect.Change) error {
+	expected, err := json.Marshal(update.Changes)
+	if err != nil {
+		return err
+	}
+	actual, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+	return &ReflectDivergenceError{GUID: update.GUID, Expected: update.Changes, Actual: changes}
+}
+
+// Produces a history of reflection metadata from each build in repo that has a
+// ReflectionMetadata file. Updates in storedHist are used as a cache, so that
+// only new builds are fetched. Builds with metadata that cannot be read are
+// reported according to opts.Strict and opts.Report, and are otherwise skipped.
+//
+// The metadata of each stored update is reconstructed from the stored history,
+// and its changes are recomputed against the preceding build. If they differ
+// from the stored changes, then a ReflectDivergenceError is returned, unless
+// opts.Repair is set, in which case every build from the divergent update
+// onward is fetched from repo.
+func MergeReflectHistory(repo *archive.Repo, storedHist *reflect.History, opts MergeOptions) (*reflect.History, error) {
+	// Map updates to GUID.
+	storedUpdates := make(map[string]int, len(storedHist.Update))
+	for i, update := range storedHist.Update {
+		storedUpdates[update.GUID] = i
+	}
+
+	fmt.Printf("loaded %d reflection updates\n", len(storedUpdates))
+
+	// Retrieve builds that have metadata, excluding later builds with the same
+	// GUID.
+	builds := repo.Builds()
+	m := make(map[string]struct{}, len(builds))
+	builds = slices.DeleteFunc(builds, func(build archive.Build) bool {
+		if !repo.Exists(build, reflectionMetadata) {
+			return true
+		}
+		if _, ok := m[build.GUID]; ok {
+			return true
+		}
+		m[build.GUID] = struct{}{}
+		return false
+	})
+
+	// Metadata at the stored update preceding index n.
+	var stored reflect.Root
+	n := 0
+	// Walk through each build, keeping a snapshot of the previous state.
+	var snapshot reflect.Root
+	updatedHist := reflect.NewHistory()
+	// Whether the stored history is being ignored.
+	repairing := false
+	for _, build := range builds {
+		if i, ok := storedUpdates[build.GUID]; ok && !repairing {
+			// Roll stored metadata to the update.
+			if i < n {
+				stored, n = reflect.Root{}, 0
+			}
+			for ; n <= i; n++ {
+				reflect.Patch(&stored, storedHist.Update[n].Changes)
+			}
+			update := storedHist.Update[i]
+			changes := reflect.Diff(snapshot, stored)
+			if err := checkReflectDivergence(update, changes); err != nil {
+				if !opts.Repair {
+					return nil, err
+				}
+				fmt.Println(err)
+				fmt.Println("repairing reflection history from", build.GUID)
+				repairing = true
+			} else {
+				reflect.Patch(&snapshot, changes)
+				updatedHist.AppendUpdate(build, update.Changes)
+				continue
+			}
+		}
+
+		fmt.Println("fetching reflection metadata", build)
+		next, err := ReadReflect(repo, build)
+		if err != nil {
+			if err := opts.report(&BuildProblem{Kind: ProblemReflect, Build: build, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		changes := reflect.Diff(snapshot, next)
+		updatedHist.AppendUpdate(build, changes)
+		fmt.Printf("\tappended %d changes\n", len(changes))
+		snapshot = next
+	}
+
+	return updatedHist, nil
+}
+
+// Reads reflection metadata history JSON from histPath.
+func ReadReflectHistory(histPath string) (storedHist *reflect.History, err error) {
+	f, err := os.Open(histPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return reflect.NewHistory(), nil
+		}
+		return nil, fmt.Errorf("open %s: %w", reflectHistoryData, err)
+	}
+	defer f.Close()
+	storedHist = reflect.NewHistory()
+	if err := json.NewDecoder(f).Decode(storedHist); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", reflectHistoryData, err)
+	}
+	return storedHist, nil
+}
@@ -3,6 +3,27 @@ package generate
 const usage = `
 Generates API data for a Hugo website.
 
+If a config path is given, then options are read from the YAML file at that
+path. Flags specified on the command line, before or after the path, override
+values from the file. Each flag corresponds to a field in the file, with disable
+flags grouped under "disable". The file may also set the names of output files
+under "output". For example:
+
+    site: site
+    source: https://raw.githubusercontent.com/RobloxAPI/build-archive/master/data/
+    docs: https://github.com/Roblox/creator-docs
+    update: true
+    disable:
+      icons: true
+    output:
+      history: History.json
+      reflect-history: ReflectHistory.json
+      index: Index.json
+      docs: Docs.json
+      dump: Dump.json
+      reflect: Reflect.json
+      search: search.db
+
 The following flags can be specified:
 
 --site string
@@ -29,7 +50,21 @@ The following flags can be specified:
         https://github.com/RobloxAPI/build-archive
 
     The source can be either a file path or a URL. A file path reads from the
-    local file system. A URL reads from a remote source via HTTP.
+    local file system. A path ending in .zip, .tar.gz, or .tgz reads from an
+    archive file containing the data directory at any depth. A URL is read
+    according to its scheme:
+
+    - file: A directory of the local file system.
+    - http, https: A remote source via HTTP.
+    - zip: A zip file, such as zip:build-archive.zip.
+    - tar.gz: A gzip-compressed tar file, such as tar.gz:build-archive.tar.gz.
+
+--source-cache string
+
+    A directory in which files read from an HTTP source are cached between runs.
+    The files of a build are kept indefinitely, while other files are
+    revalidated with the server each time they are read. If unspecified, then
+    files are not cached.
 
 --docs string
 
@@ -45,6 +80,60 @@ The following flags can be specified:
 	If specified, then an existing history database will not be read, and the
 	history will be generated from scratch.
 
+--jobs int
+
+    The number of API dumps fetched and decoded concurrently while updating the
+    history database. Defaults to the number of CPUs.
+
+--full
+
+    By default, updating the history database appends builds newer than the
+    latest update of the cached history. Only the latest cached update is
+    recomputed and compared against the cache; earlier cached updates are not
+    verified, and nothing is verified if there are no new builds. If a new
+    build is older than the latest cached update, then the history is rebuilt
+    entirely. If --full is specified, then the history is always rebuilt,
+    recomputing each cached update and comparing it against the cache.
+    Verifying the entire cached history requires --full.
+
+--checkpoints string
+
+    A directory in which full API dumps at periodic updates of the history are
+    kept. When appending builds to the cached history, the dump of the latest
+    update is reconstructed from the nearest checkpoint, rather than from the
+    first update. Checkpoints that no longer match the history, such as after
+    --no-cache or --repair, are discarded and rebuilt. If unspecified, then
+    checkpoints are not kept.
+
+--checkpoint-interval int
+
+    The number of updates between each checkpoint. Defaults to 100.
+
+--repair
+
+    Each update of the cached history that is recomputed, which is every update
+    with --full and otherwise only the latest, is compared against the cache. If
+    they differ, then the command normally fails, reporting the differing
+    actions. The cached reflection metadata history is compared in the same way.
+    If --repair is specified, then the history is instead rebuilt from the
+    divergent update onward, by fetching each remaining build from the source.
+    Implies --full.
+
+--strict
+
+    When updating the history database, a build may be skipped because its API
+    dump is missing or cannot be decoded, or because its reflection metadata
+    cannot be parsed, and the history may be rebuilt when cached history cannot
+    be applied. Such problems are normally summarized after the history is
+    updated. If --strict is specified, then the command instead fails on the
+    first problem.
+
+--compact-search
+
+    If specified, then the rows of the search database are encoded in a compact
+    format, where each row only includes the bytes that are set. This reduces
+    the size of the database, at the cost of slower decoding.
+
 --disable-index
 
 	Whether index data will be generated.
@@ -59,7 +148,25 @@ The following flags can be specified:
 
 --disable-reflect
 
-    Whether reflection metadata will be generated.
+    Whether reflection metadata will be generated. Metadata is read from the
+    ReflectionMetadata.xml file of the latest build that has one, and is written
+    to data/Reflect.json.
+
+    When --update is specified, a history of changes to reflection metadata
+    across all builds is also produced, and is written to
+    data/ReflectHistory.json. Like the history database, the existing file is
+    used as a cache unless --no-cache is specified.
+
+--disable-docs
+
+    Whether documentation data will be generated.
+
+--disable-search-docs
+
+    Whether documentation will be indexed by the search database. When
+    enabled, the text of documentation in data/Docs.json is tokenized and
+    included in the database, allowing entities to be found by words in their
+    documentation.
 
 --disable-pages
 
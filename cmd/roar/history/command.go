@@ -0,0 +1,197 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/roar/cmd/roar/generate"
+	"github.com/robloxapi/roar/history"
+	"github.com/robloxapi/roar/id"
+)
+
+var Def = snek.Def{
+	Name: "history",
+	Doc: snek.Doc{
+		Summary:     "Display the history of an entity.",
+		Arguments:   "[flags] <entity>",
+		Description: usage,
+	},
+	New: func() snek.Command { return &Command{} },
+}
+
+type Command struct {
+	Site   string
+	Config string
+}
+
+func (c *Command) SetFlags(flagset snek.FlagSet) {
+	flagset.StringVar(&c.Site, "site", "", "Location of Hugo site.")
+	flagset.StringVar(&c.Config, "config", "", "Config file of the generate command.")
+}
+
+func (c *Command) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 1 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+
+	site, output, err := generate.ReadOutputConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	if c.Site == "" {
+		c.Site = site
+	}
+	histPath := filepath.Join(c.Site, generate.SiteData, output.History)
+	if _, err := os.Stat(histPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("history not found at %s", histPath)
+	}
+	hist, err := generate.ReadHistory(histPath)
+	if err != nil {
+		return err
+	}
+
+	entity := opt.Arg(0)
+	changes, refs, ok := Lookup(hist, entity)
+	if !ok {
+		return fmt.Errorf("no history for %s", entity)
+	}
+	if refs != nil {
+		return writeTypeRefs(opt.Stdout, refs)
+	}
+	return writeChanges(opt.Stdout, changes)
+}
+
+// Finds the history of an entity. The entity is one of the following forms:
+//
+//   - Class or Enum
+//   - Class.Member, Class:Member, or Enum.Item
+//   - Class:Name, Enum:Name, or Type:Name
+//
+// If the entity is a type, then a list of type references is returned.
+// Otherwise, a list of changes is returned. Returns false if the entity has no
+// history.
+func Lookup(hist *history.Root, entity string) (changes []*history.Change, refs []*history.TypeRef, ok bool) {
+	if kind, name, found := strings.Cut(entity, ":"); found {
+		switch kind {
+		case "Class":
+			changes, ok = hist.Object.Class[name]
+			return changes, nil, ok
+		case "Enum":
+			changes, ok = hist.Object.Enum[name]
+			return changes, nil, ok
+		case "Type":
+			refs, ok = hist.Object.Type[name]
+			return nil, refs, ok
+		}
+		// Method syntax.
+		entity = kind + "." + name
+	}
+	if primary, secondary, found := strings.Cut(entity, "."); found {
+		if changes, ok = hist.Object.Member[id.MemberID{Class: primary, Member: secondary}]; ok {
+			return changes, nil, true
+		}
+		changes, ok = hist.Object.EnumItem[id.EnumItemID{Enum: primary, EnumItem: secondary}]
+		return changes, nil, ok
+	}
+	if changes, ok = hist.Object.Class[entity]; ok {
+		return changes, nil, true
+	}
+	changes, ok = hist.Object.Enum[entity]
+	return changes, nil, ok
+}
+
+// Returns the name of the entity to which action applies.
+func entityName(action diff.Action) string {
+	if action.Secondary != "" {
+		return action.Element.String() + " " + action.Primary + "." + action.Secondary
+	}
+	return action.Element.String() + " " + action.Primary
+}
+
+// Writes the update information of a change.
+func writeUpdate(w io.Writer, change *history.Change) error {
+	update := change.Update
+	_, err := fmt.Fprintf(w, "%s  %s  %s  ",
+		update.Date.Format("2006-01-02"),
+		update.Version,
+		update.GUID,
+	)
+	return err
+}
+
+// Writes each change on a line, ordered by date.
+func writeChanges(w io.Writer, changes []*history.Change) error {
+	history.SortChanges(changes)
+	for _, change := range changes {
+		action := change.Action
+		if action.Type != diff.Change {
+			if err := writeUpdate(w, change); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s %s\n", action.Type, entityName(action)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, field := range slices.Sorted(maps.Keys(action.Fields)) {
+			if err := writeUpdate(w, change); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintf(w, "%s %s of %s from %s to %s\n",
+				action.Type,
+				field,
+				entityName(action),
+				history.FormatValue(change.Prev[field]),
+				history.FormatValue(action.Fields[field]),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Writes each type reference on a line, ordered by date.
+func writeTypeRefs(w io.Writer, refs []*history.TypeRef) error {
+	refs = slices.Clone(refs)
+	slices.SortStableFunc(refs, func(a, b *history.TypeRef) int {
+		return a.Change.Update.Date.Compare(b.Change.Update.Date)
+	})
+	for _, ref := range refs {
+		if err := writeUpdate(w, ref.Change); err != nil {
+			return err
+		}
+		action := ref.Change.Action
+		field := ref.Field
+		if ref.Index >= 0 {
+			field = fmt.Sprintf("%s[%d]", field, ref.Index)
+		}
+		var err error
+		if action.Type == diff.Change {
+			source := "to"
+			if ref.Prev {
+				source = "from"
+			}
+			_, err = fmt.Fprintf(w, "%s %s of %s %s %s\n", action.Type, field, entityName(action), source, ref.Value)
+		} else {
+			_, err = fmt.Fprintf(w, "%s %s with %s %s\n", action.Type, entityName(action), field, ref.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package history
+
+const usage = `
+Displays the history of a single entity, as recorded by the history database of
+a site generated with the generate command.
+
+The entity is specified in one of the following forms:
+
+    Class          A class or enum.
+    Class.Member   A class member or enum item. "Class:Member" is also
+                   accepted.
+    Class:Name     A class.
+    Enum:Name      An enum.
+    Type:Name      A type. Lists each change that refers to the type.
+
+Each change is displayed on a line with the date, version, and GUID of the
+update in which it occurred, followed by the type of change. Changes to a field
+include the values before and after the change.
+
+The following flags can be specified:
+
+--site string
+
+    The path to the Hugo site from which history data will be read. The history
+    database is expected to be located at data/History.json, unless renamed by
+    the config file.
+
+--config string
+
+    The path to a config file of the generate command. The site and the names
+    of output files are read from the file. The --site flag overrides the site
+    of the file.
+
+`
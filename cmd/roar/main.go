@@ -5,13 +5,23 @@ import (
 	"os"
 
 	"github.com/anaminus/snek"
+	"github.com/robloxapi/roar/cmd/roar/archive"
+	"github.com/robloxapi/roar/cmd/roar/diff"
+	"github.com/robloxapi/roar/cmd/roar/dump"
 	"github.com/robloxapi/roar/cmd/roar/generate"
+	"github.com/robloxapi/roar/cmd/roar/history"
+	"github.com/robloxapi/roar/cmd/roar/search"
 )
 
 var Program = snek.NewProgram("roar", os.Args)
 
 func init() {
+	Program.Register(archive.Def)
+	Program.Register(diff.Def)
+	Program.Register(dump.Def)
 	Program.Register(generate.Def)
+	Program.Register(history.Def)
+	Program.Register(search.Def)
 }
 
 func main() {
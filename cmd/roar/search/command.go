@@ -0,0 +1,121 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anaminus/snek"
+	"github.com/robloxapi/roar/cmd/roar/generate"
+	"github.com/robloxapi/roar/index"
+	"github.com/robloxapi/roar/search"
+)
+
+var Def = snek.Def{
+	Name: "search",
+	Doc: snek.Doc{
+		Summary:     "Search the API with a query.",
+		Arguments:   "[flags] <query>",
+		Description: usage,
+	},
+	New: func() snek.Command { return &Command{} },
+}
+
+type Command struct {
+	Site   string
+	Config string
+	DB     string
+	Strict bool
+	Scores bool
+}
+
+func (c *Command) SetFlags(flagset snek.FlagSet) {
+	flagset.StringVar(&c.Site, "site", "", "Location of Hugo site.")
+	flagset.StringVar(&c.Config, "config", "", "Config file of the generate command.")
+	flagset.StringVar(&c.DB, "db", "", "Location of search database.")
+	flagset.BoolVar(&c.Strict, "strict", false, "Fail on malformed queries.")
+	flagset.BoolVar(&c.Scores, "scores", false, "Display the score of each result.")
+}
+
+func (c *Command) Run(opt snek.Options) error {
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.NArg() != 1 {
+		opt.WriteUsageOf(opt.Stderr, opt.Def)
+		return nil
+	}
+
+	db, err := c.loadDB()
+	if err != nil {
+		return err
+	}
+
+	query, err := db.Parse(opt.Arg(0))
+	if err != nil {
+		if c.Strict {
+			return fmt.Errorf("parse query: %w", err)
+		}
+		// Fallback to fuzzy search, as the site does.
+		query = db.Fuzzy(opt.Arg(0))
+	}
+
+	results := db.Search(query)
+	if len(results) == 0 {
+		fmt.Fprintln(opt.Stderr, "no results found")
+		return nil
+	}
+	for _, result := range query.Truncate(results) {
+		if c.Scores {
+			fmt.Fprintf(opt.Stdout, "%g\t%s\n", result.Score, result)
+		} else {
+			fmt.Fprintln(opt.Stdout, result)
+		}
+	}
+	return nil
+}
+
+// Loads the search database from the DB flag if set, or otherwise builds it
+// from the history and documentation data of the site.
+func (c *Command) loadDB() (*search.DB, error) {
+	if c.DB != "" {
+		f, err := os.Open(c.DB)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return search.Read(f)
+	}
+
+	site, output, err := generate.ReadOutputConfig(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	if c.Site == "" {
+		c.Site = site
+	}
+	histPath := filepath.Join(c.Site, generate.SiteData, output.History)
+	if _, err := os.Stat(histPath); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("history not found at %s", histPath)
+	}
+	hist, err := generate.ReadHistory(histPath)
+	if err != nil {
+		return nil, err
+	}
+	dump := generate.PatchDump(hist)
+	idx := &index.Root{}
+	if err := idx.Build(hist, dump); err != nil {
+		return nil, err
+	}
+	doc, err := generate.ReadDocs(filepath.Join(c.Site, generate.SiteData, output.Docs))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := search.Encode(&buf, idx, dump, hist, doc, search.FormatFixed); err != nil {
+		return nil, err
+	}
+	return search.Read(&buf)
+}
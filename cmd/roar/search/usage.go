@@ -0,0 +1,56 @@
+package search
+
+const usage = `
+Searches the API using the same query syntax as the site's search bar. The
+syntax is described by the search cheat sheet of the site (content/search.md).
+For example:
+
+    roar search 'is:function paramtypename:Instance'
+    roar search 'Part.Size'
+    roar search 'security: /limit:10'
+    roar search 'is:class added:>=2024-01-01'
+
+By default, entities are read from the history database of a site generated
+with the generate command. Entities that have been removed are included. If the
+site has documentation data, then entities can also be found by words in their
+documentation.
+
+Each result is displayed on a line with the entity type and name, or the listed
+value when the query contains list selectors. Results are sorted descending by
+score. As with the site, at most 50 results are displayed unless the query
+contains a /limit selector. Unlike the site, results are not filtered by
+visibility settings.
+
+If the query cannot be parsed, then the entire query is fuzzily matched against
+entity names, as the site does.
+
+The following flags can be specified:
+
+--site string
+
+    The path to the Hugo site from which history data will be read. The history
+    database is expected to be located at data/History.json. Documentation is
+    read from data/Docs.json, if present. These files may be renamed by the
+    config file.
+
+--config string
+
+    The path to a config file of the generate command. The site and the names
+    of output files are read from the file. The --site flag overrides the site
+    of the file.
+
+--db string
+
+    The path to a search database produced by the generate command. If
+    specified, then entities are read from the database instead of the site.
+
+--strict
+
+    Report an error if the query cannot be parsed, instead of falling back to a
+    fuzzy search.
+
+--scores
+
+    Display the score of each result before the result.
+
+`
@@ -0,0 +1,233 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robloxapi/rbxdump"
+	rbxdumpjson "github.com/robloxapi/rbxdump/json"
+)
+
+// A set of full API dumps at particular updates. A Cursor uses checkpoints to
+// avoid rolling from the start of a chain.
+//
+// Checkpoints may be kept in a directory, with each dump stored as an API dump
+// JSON file named after the GUID and fingerprint of its update. Dumps are
+// loaded from the directory only as needed.
+type Checkpoints struct {
+	dir string
+	// Maps the GUID of an update to its checkpoint.
+	points map[string]*checkpoint
+	// Names of files in dir that are not checkpoints of the history.
+	stale []string
+}
+
+// A dump at a particular update.
+type checkpoint struct {
+	// Fingerprint of the update the dump was produced from.
+	fingerprint string
+	// Dump at the update. A nil dump has not yet been loaded from the
+	// directory.
+	dump *rbxdump.Root
+}
+
+// Extension of checkpoint files.
+const checkpointExt = ".json"
+
+// Returns a new empty set of checkpoints, kept only in memory.
+func NewCheckpoints() *Checkpoints {
+	return &Checkpoints{points: map[string]*checkpoint{}}
+}
+
+// Opens the checkpoints kept in dir for hist. The directory is not required to
+// exist. Checkpoints whose fingerprint does not match the corresponding update
+// of hist, such as those kept for a history that has since been rebuilt, are
+// ignored, and are removed by the next call to Update.
+func OpenCheckpoints(dir string, hist *Root) (*Checkpoints, error) {
+	c := &Checkpoints{dir: dir, points: map[string]*checkpoint{}}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	fingerprints := fingerprints(hist)
+	updates := make(map[string]*Update, len(hist.Update))
+	for _, update := range hist.Update {
+		updates[update.GUID] = update
+	}
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), checkpointExt)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		guid, fingerprint, _ := strings.Cut(base, ".")
+		if update, ok := updates[guid]; !ok || fingerprint != fingerprints[update] {
+			c.stale = append(c.stale, entry.Name())
+			continue
+		}
+		c.points[guid] = &checkpoint{fingerprint: fingerprint}
+	}
+	return c, nil
+}
+
+// Returns a fingerprint of the state of each update of hist. The fingerprint of
+// an update is derived from the GUID and changes of the update and of each
+// preceding update, so it differs whenever the dump at the update may differ.
+func fingerprints(hist *Root) map[*Update]string {
+	fingerprints := make(map[*Update]string, len(hist.Update))
+	h := sha256.New()
+	je := json.NewEncoder(h)
+	for _, update := range hist.Update {
+		je.Encode(update.GUID)
+		for _, change := range update.Changes {
+			je.Encode(change.Action)
+		}
+		fingerprints[update] = hex.EncodeToString(h.Sum(nil)[:8])
+	}
+	return fingerprints
+}
+
+// Returns the path of the checkpoint file of the update with the given GUID.
+func (c *Checkpoints) file(guid, fingerprint string) string {
+	return filepath.Join(c.dir, guid+"."+fingerprint+checkpointExt)
+}
+
+// Returns whether there is a checkpoint for the update with the given GUID.
+// Returns false if c is nil.
+func (c *Checkpoints) Has(guid string) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.points[guid]
+	return ok
+}
+
+// Returns the dump at the update with the given GUID, loading it if necessary.
+// Returns nil if there is no such checkpoint, or if c is nil. The returned dump
+// must not be modified.
+func (c *Checkpoints) Get(guid string) (*rbxdump.Root, error) {
+	if c == nil {
+		return nil, nil
+	}
+	point, ok := c.points[guid]
+	if !ok {
+		return nil, nil
+	}
+	if point.dump != nil {
+		return point.dump, nil
+	}
+	f, err := os.Open(c.file(guid, point.fingerprint))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dump, err := rbxdumpjson.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", guid, err)
+	}
+	point.dump = dump
+	return dump, nil
+}
+
+// Ensures that there is a checkpoint at every interval-th update of hist.
+// Checkpoints of other updates, and checkpoints that do not match hist, are
+// removed. New checkpoints are written to the directory of c, if any. An
+// interval less than 1 removes every checkpoint.
+func (c *Checkpoints) Update(hist *Root, interval int) error {
+	fingerprints := fingerprints(hist)
+	want := map[string]*Update{}
+	if interval > 0 {
+		for i := interval - 1; i < len(hist.Update); i += interval {
+			want[hist.Update[i].GUID] = hist.Update[i]
+		}
+	}
+	for guid, point := range c.points {
+		if update, ok := want[guid]; ok && point.fingerprint == fingerprints[update] {
+			continue
+		}
+		delete(c.points, guid)
+		if c.dir != "" {
+			c.stale = append(c.stale, filepath.Base(c.file(guid, point.fingerprint)))
+		}
+	}
+	for _, name := range c.stale {
+		err := os.Remove(filepath.Join(c.dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	c.stale = nil
+
+	cursor := Cursor{Checkpoints: c}
+	for _, update := range hist.Update {
+		if _, ok := want[update.GUID]; !ok || c.Has(update.GUID) {
+			continue
+		}
+		if !cursor.Roll(update) {
+			return fmt.Errorf("failed to roll cursor to %s", update.GUID)
+		}
+		point := &checkpoint{
+			fingerprint: fingerprints[update],
+			dump:        cursor.Dump.Copy(),
+		}
+		if c.dir != "" {
+			if err := c.write(update.GUID, point); err != nil {
+				return err
+			}
+		}
+		c.points[update.GUID] = point
+	}
+	return nil
+}
+
+// Writes the dump of a checkpoint to the directory of c.
+func (c *Checkpoints) write(guid string, point *checkpoint) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	path := c.file(guid, point.fingerprint)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = rbxdumpjson.Encode(f, point.dump)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("encode checkpoint %s: %w", guid, err)
+	}
+	return nil
+}
+
+// Returns the dump at the checkpoint nearest to and preceding target within
+// its chain, along with the update of the checkpoint. Only checkpoints fewer
+// than limit updates from target are considered, or every checkpoint if limit
+// is negative. Returns nil if no such checkpoint exists.
+func (c *Checkpoints) nearest(target *Update, limit int) (*Update, *rbxdump.Root) {
+	if c == nil || len(c.points) == 0 {
+		return nil, nil
+	}
+	for update, n := target, 0; update != nil && (limit < 0 || n < limit); update, n = update.Prev, n+1 {
+		if !c.Has(update.GUID) {
+			continue
+		}
+		dump, err := c.Get(update.GUID)
+		if err != nil || dump == nil {
+			// Fall back to rolling without the checkpoint.
+			continue
+		}
+		return update, dump
+	}
+	return nil, nil
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/robloxapi/rbxdump"
+	rbxdumpjson "github.com/robloxapi/rbxdump/json"
+)
+
+// Returns the names of the files in dir.
+func checkpointFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// Adds a class named "Marker" to the checkpoint file of the update with the
+// given GUID, so that rolling from the checkpoint can be detected.
+func markCheckpoint(t *testing.T, dir, guid string) {
+	t.Helper()
+	for _, name := range checkpointFiles(t, dir) {
+		if !strings.HasPrefix(name, guid+".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump, err := rbxdumpjson.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump.Classes["Marker"] = &rbxdump.Class{Name: "Marker", Members: map[string]rbxdump.Member{}}
+		f, err = os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := rbxdumpjson.Encode(f, dump); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	t.Fatalf("no checkpoint for %s", guid)
+}
+
+func TestCheckpointsUpdate(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	dir := t.TempDir()
+	cps, err := OpenCheckpoints(dir, hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cps.Update(hist, 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(checkpointFiles(t, dir)); n != testDumps/3 {
+		t.Fatalf("expected %d checkpoint files, got %d", testDumps/3, n)
+	}
+	for i, update := range hist.Update {
+		if want := i%3 == 2; cps.Has(update.GUID) != want {
+			t.Errorf("update %d: expected checkpoint %t", i, want)
+		}
+	}
+
+	// Reopened checkpoints produce the same dumps as a full replay.
+	cps, err = OpenCheckpoints(dir, hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range hist.Update {
+		cursor := Cursor{Checkpoints: cps}
+		if !cursor.Roll(hist.Update[i]) {
+			t.Fatalf("roll to %d failed", i)
+		}
+		checkRoll(t, &cursor, hist, i, 0, 0)
+	}
+
+	// An interval less than 1 removes every checkpoint.
+	if err := cps.Update(hist, 0); err != nil {
+		t.Fatal(err)
+	}
+	if names := checkpointFiles(t, dir); len(names) != 0 {
+		t.Fatalf("expected no checkpoint files, got %v", names)
+	}
+}
+
+func TestCursorRollCheckpoint(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	dir := t.TempDir()
+	cps, err := OpenCheckpoints(dir, hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cps.Update(hist, 3); err != nil {
+		t.Fatal(err)
+	}
+	markCheckpoint(t, dir, hist.Update[5].GUID)
+	markCheckpoint(t, dir, hist.Update[8].GUID)
+	if cps, err = OpenCheckpoints(dir, hist); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		from, to   int
+		checkpoint bool
+	}{
+		{name: "Start", from: -1, to: 6, checkpoint: true},
+		{name: "AtCheckpoint", from: -1, to: 5, checkpoint: true},
+		{name: "BeforeCheckpoint", from: -1, to: 4, checkpoint: false},
+		{name: "ForwardCloser", from: 3, to: 6, checkpoint: true},
+		{name: "ForwardCurrentCloser", from: 6, to: 7, checkpoint: false},
+		{name: "ForwardSameDistance", from: 5, to: 7, checkpoint: false},
+		{name: "BackwardCurrentCloser", from: 7, to: 6, checkpoint: false},
+		{name: "BackwardCheckpointCloser", from: 7, to: 5, checkpoint: true},
+		// Rolling backward over the removal of Part is not reversible.
+		{name: "BackwardIrreversible", from: 10, to: 9, checkpoint: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cursor Cursor
+			if test.from >= 0 {
+				cursor.Roll(hist.Update[test.from])
+			}
+			cursor.Checkpoints = cps
+			if !cursor.Roll(hist.Update[test.to]) {
+				t.Fatalf("roll to %d failed", test.to)
+			}
+			_, marked := cursor.Dump.Classes["Marker"]
+			if marked != test.checkpoint {
+				t.Fatalf("expected rolling from checkpoint to be %t", test.checkpoint)
+			}
+		})
+	}
+}
+
+func TestCheckpointsStale(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	dir := t.TempDir()
+	cps, err := OpenCheckpoints(dir, hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cps.Update(hist, 3); err != nil {
+		t.Fatal(err)
+	}
+	before := checkpointFiles(t, dir)
+
+	// Files not named as checkpoints of the history are ignored.
+	for _, name := range []string{"version-0002.json", "version-ffff.0123456789abcdef.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A history rewritten from update 6 onward shares only the checkpoints
+	// before it.
+	rewritten := testHistory(t, 1, 6)
+	if cps, err = OpenCheckpoints(dir, rewritten); err != nil {
+		t.Fatal(err)
+	}
+	for i, update := range rewritten.Update {
+		if want := i%3 == 2 && i < 6; cps.Has(update.GUID) != want {
+			t.Errorf("update %d: expected checkpoint %t", i, want)
+		}
+	}
+	for i := range rewritten.Update {
+		cursor := Cursor{Checkpoints: cps}
+		if !cursor.Roll(rewritten.Update[i]) {
+			t.Fatalf("roll to %d failed", i)
+		}
+		checkRoll(t, &cursor, rewritten, i, 1, 6)
+	}
+
+	// Updating removes stale files and writes new checkpoints.
+	if err := cps.Update(rewritten, 3); err != nil {
+		t.Fatal(err)
+	}
+	after := checkpointFiles(t, dir)
+	if len(after) != testDumps/3 {
+		t.Fatalf("expected %d checkpoint files, got %v", testDumps/3, after)
+	}
+	for _, name := range before {
+		kept := slices.Contains(after, name)
+		if want := name < "version-0006"; kept != want {
+			t.Errorf("%s: expected kept to be %t", name, want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package history
+
+import (
+	"time"
+
+	"github.com/robloxapi/roar/archive"
+)
+
+// Returns the update referred to by query, which is interpreted as described
+// by archive.Repo.Find, with "previous" referring to the update before the
+// latest update.
+//
+// Returns archive.ErrBuildNotFound if no update matches, or an
+// archive.AmbiguousError if a GUID prefix matches several updates.
+func (r Root) Find(query string) (*Update, error) {
+	updates := r.UpdateRange(time.Time{}, time.Time{})
+	i, err := archive.FindIndex(updates, query, func(update *Update) archive.Build {
+		return archive.Build{GUID: update.GUID, Date: update.Date, Version: update.Version}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updates[i], nil
+}
@@ -4,8 +4,10 @@ package history
 import (
 	"cmp"
 	"encoding/json"
+	"fmt"
 	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/robloxapi/rbxdump"
@@ -99,7 +101,7 @@ func (r *Root) UnmarshalJSON(b []byte) error {
 	for cid, jchange := range jr.Change {
 		r.Change[cid] = &Change{
 			Action: jchange.Action,
-			Prev:   jchange.Prev,
+			Prev:   decodePrev(jchange.Action, jchange.Prev),
 		}
 	}
 
@@ -160,6 +162,20 @@ func (r *Root) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Converts the generic JSON structure of the previous fields of a change to
+// rbxdump values, in the same way as the fields of the action.
+func decodePrev(action diff.Action, prev rbxdump.Fields) rbxdump.Fields {
+	if len(prev) == 0 {
+		return prev
+	}
+	f := action.ToFielder()
+	if f == nil {
+		return prev
+	}
+	f.SetFields(prev)
+	return f.Fields(prev)
+}
+
 func (r *Root) decodeChanges(cids []changeID) []*Change {
 	changes := make([]*Change, len(cids))
 	for i, cid := range cids {
@@ -353,6 +369,79 @@ func Actions(update *Update) []diff.Action {
 	return actions
 }
 
+// Sorts a list of actions in a consistent order. Actions are ordered by primary
+// element type, primary name, element type, secondary name, and action type.
+func SortActions(actions []diff.Action) {
+	sort.Slice(actions, func(i, j int) bool {
+		if pi, pj := actions[i].Element.Primary(), actions[j].Element.Primary(); pi != pj {
+			return pi < pj
+		}
+		if actions[i].Primary != actions[j].Primary {
+			return actions[i].Primary < actions[j].Primary
+		}
+		if actions[i].Element != actions[j].Element {
+			return actions[i].Element < actions[j].Element
+		}
+		if actions[i].Secondary != actions[j].Secondary {
+			return actions[i].Secondary < actions[j].Secondary
+		}
+		if actions[i].Type != actions[j].Type {
+			return actions[i].Type > actions[j].Type
+		}
+		var fieldi, fieldj string
+		for field := range actions[i].Fields {
+			fieldi = field
+			break
+		}
+		for field := range actions[i].Fields {
+			fieldj = field
+			break
+		}
+		return fieldi < fieldj
+	})
+}
+
+// Returns a human-readable representation of a field value. Empty values are
+// represented as "<none>".
+func FormatValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		if value == "" {
+			return "<none>"
+		}
+		return value
+	case rbxdump.Type:
+		return value.String()
+	case []rbxdump.Type:
+		s := make([]string, len(value))
+		for i, typ := range value {
+			s[i] = typ.String()
+		}
+		return "(" + strings.Join(s, ", ") + ")"
+	case []rbxdump.Parameter:
+		s := make([]string, len(value))
+		for i, param := range value {
+			s[i] = param.Name + ": " + param.Type.String()
+			if param.Optional {
+				s[i] += " = " + param.Default
+			}
+		}
+		return "(" + strings.Join(s, ", ") + ")"
+	case rbxdump.Tags:
+		return "[" + strings.Join(value, ", ") + "]"
+	case []string:
+		return "[" + strings.Join(value, ", ") + "]"
+	case rbxdump.PreferredDescriptor:
+		if value.Name == "" {
+			return "<none>"
+		}
+		return value.Name
+	}
+	return fmt.Sprint(value)
+}
+
 // Returns a map of entries in values filtered to include only keys from keys.
 func filterFields(keys, values rbxdump.Fields) rbxdump.Fields {
 	result := make(rbxdump.Fields, len(keys))
@@ -504,6 +593,52 @@ func (r *Root) addTypeRefs(value any, ref TypeRef) {
 type Cursor struct {
 	Target *Update
 	Dump   *rbxdump.Root
+	// If non-nil, the cursor begins rolling from the nearest checkpoint
+	// preceding the target, when it is closer than the current target.
+	Checkpoints *Checkpoints
+}
+
+// Returns the direction in which the cursor rolls from c.Target to reach
+// target, along with the number of updates between them. The direction is 1
+// for forward, -1 for backward, and 0 if target is not in the chain of
+// c.Target.
+func (c *Cursor) distance(target *Update) (direction, n int) {
+	if c.Target == nil {
+		return 0, 0
+	}
+	n = 1
+	for next := c.Target.Next; next != nil; next = next.Next {
+		if next == target {
+			return 1, n
+		}
+		n++
+	}
+	n = 1
+	for prev := c.Target.Prev; prev != nil; prev = prev.Prev {
+		if prev == target {
+			return -1, n
+		}
+		n++
+	}
+	return 0, 0
+}
+
+// Returns whether rolling backward from update to target, which precedes
+// update, reproduces the state of target. Undoing the removal of a class or
+// enum restores only the entity itself, and not its members or items.
+func reversible(update, target *Update) bool {
+	for ; update != target; update = update.Prev {
+		for _, change := range update.Changes {
+			if change.Action.Type != diff.Remove {
+				continue
+			}
+			switch change.Action.Element {
+			case diff.Class, diff.Enum:
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // Rolls the cursor's current target forward or backward until it hits target.
@@ -511,6 +646,10 @@ type Cursor struct {
 // c.Target is nil, the cursor rolls from the start of target's chain, until it
 // reaches target. If true is returned, then c.Dump has been patched to
 // represent the state of the target update.
+//
+// The cursor instead begins from the start of the chain when rolling backward
+// cannot reproduce the state of target, or from the nearest checkpoint when it
+// is closer to target than c.Target.
 func (c *Cursor) Roll(target *Update) bool {
 	if target == nil {
 		return false
@@ -519,14 +658,27 @@ func (c *Cursor) Roll(target *Update) bool {
 		return true
 	}
 
-	var direction int
-	var start *Update
-	if c.Target == nil {
-		// Begin at start of chain, rolling forward until target.
+	direction, distance := c.distance(target)
+	if c.Target != nil && direction == 0 {
+		// target is not in chain of c.Target.
+		return false
+	}
+	if direction < 0 && !reversible(c.Target, target) {
+		direction = 0
+	}
+	limit := distance
+	if direction == 0 {
+		limit = -1
+	}
+	if update, dump := c.Checkpoints.nearest(target, limit); update != nil {
+		c.Target = update
+		c.Dump = dump.Copy()
 		direction = 1
-		start = target
-		for prev := target.Prev; prev != nil; prev = prev.Prev {
-			start = prev
+	} else if direction == 0 {
+		// Begin at start of chain, rolling forward until target.
+		start := target
+		for start.Prev != nil {
+			start = start.Prev
 		}
 		// Produce dump of start.
 		c.Dump = &rbxdump.Root{}
@@ -536,60 +688,34 @@ func (c *Cursor) Roll(target *Update) bool {
 			patcher.Patch(actions[:])
 		}
 		c.Target = start
-		if target == c.Target {
-			return true
-		}
-	} else {
-		for next := c.Target.Next; next != nil; next = next.Next {
-			if next == target {
-				// Begin at current, rolling forward until target.
-				direction = 1
-				start = c.Target.Next
-				goto roll
-			}
-		}
-		for prev := c.Target.Prev; prev != nil; prev = prev.Prev {
-			if prev == target {
-				// Begin at current, rolling backward until target.
-				direction = -1
-				start = c.Target.Prev
-				goto roll
-			}
-		}
+		direction = 1
 	}
-roll:
+
 	patcher := diff.Patch{Root: c.Dump}
-	switch direction {
-	case 1:
-		for update := start; ; update = update.Next {
+	if direction > 0 {
+		for c.Target != target {
+			update := c.Target.Next
 			for _, change := range update.Changes {
 				actions := [1]diff.Action{change.Action}
 				patcher.Patch(actions[:])
 			}
 			c.Target = update
-			if update == target {
-				break
-			}
 		}
 		return true
-	case -1:
-		for update := start; ; update = update.Prev {
-			for _, change := range update.Changes {
-				actions := [1]diff.Action{change.Action}
-				actions[0].Type = -actions[0].Type
-				actions[0].Fields = change.Prev
-				patcher.Patch(actions[:])
-			}
-			c.Target = update
-			if update == target {
-				break
-			}
+	}
+	for c.Target != target {
+		// Undo the changes of the current update, in reverse order.
+		update := c.Target
+		for i := len(update.Changes) - 1; i >= 0; i-- {
+			change := update.Changes[i]
+			actions := [1]diff.Action{change.Action}
+			actions[0].Type = -actions[0].Type
+			actions[0].Fields = change.Prev
+			patcher.Patch(actions[:])
 		}
-		return true
-	default:
-		// target is not in chain of c.Target.
-		return false
+		c.Target = update.Prev
 	}
+	return true
 }
 
 // Visits tags within a history.Root. If ok is true, then the tag will be
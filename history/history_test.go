@@ -0,0 +1,164 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robloxapi/rbxdump"
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/rbxver"
+	"github.com/robloxapi/roar/archive"
+)
+
+// Number of dumps produced by testDump.
+const testDumps = 12
+
+// Returns the dump of the i-th test build. Successive dumps change a property,
+// add and remove members, remove classes and enums along with their members and
+// items, and add them back again. Dumps with a seed other than 0 diverge from
+// the dumps of seed 0 from the given index onward.
+func testDump(i, seed, from int) *rbxdump.Root {
+	category := fmt.Sprintf("Category%d", i%3)
+	if seed != 0 && i >= from {
+		category = fmt.Sprintf("Seed%d", seed)
+	}
+	instance := &rbxdump.Class{
+		Name: "Instance",
+		Members: map[string]rbxdump.Member{
+			"Name": &rbxdump.Property{
+				Name:      "Name",
+				ValueType: rbxdump.Type{Category: "DataType", Name: "string"},
+				Category:  category,
+			},
+		},
+	}
+	if i%2 == 0 {
+		instance.Members["Destroy"] = &rbxdump.Function{Name: "Destroy", Security: "None"}
+	}
+	root := &rbxdump.Root{
+		Classes: map[string]*rbxdump.Class{"Instance": instance},
+		Enums:   map[string]*rbxdump.Enum{},
+	}
+	if i%4 < 2 {
+		root.Classes["Part"] = &rbxdump.Class{
+			Name:       "Part",
+			Superclass: "Instance",
+			Members: map[string]rbxdump.Member{
+				"Size":   &rbxdump.Property{Name: "Size", ValueType: rbxdump.Type{Category: "DataType", Name: "Vector3"}},
+				"Resize": &rbxdump.Function{Name: "Resize", Security: "None"},
+			},
+		}
+	}
+	if i%5 != 4 {
+		enum := &rbxdump.Enum{Name: "Material", Items: map[string]*rbxdump.EnumItem{}}
+		for v := 0; v <= i%3; v++ {
+			name := fmt.Sprintf("Item%d", v)
+			enum.Items[name] = &rbxdump.EnumItem{Name: name, Value: v, Index: v}
+		}
+		root.Enums["Material"] = enum
+	}
+	return root
+}
+
+// Returns a history of the test dumps.
+func testHistory(t *testing.T, seed, from int) *Root {
+	t.Helper()
+	hist := NewRoot()
+	differ := diff.Diff{Prev: &rbxdump.Root{}, SeparateFields: true}
+	for i := 0; i < testDumps; i++ {
+		build := archive.Build{
+			GUID:    fmt.Sprintf("version-%04x", i),
+			Date:    time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Version: rbxver.Version{Version: 600 + i, Commit: i},
+		}
+		differ.Next = testDump(i, seed, from)
+		actions := differ.Diff()
+		SortActions(actions)
+		hist.AppendUpdate(build, actions, differ.Prev)
+		differ.Prev = differ.Next.Copy()
+	}
+	return hist
+}
+
+// Produces the dump at target by applying the changes of every update from
+// the start of its chain.
+func replay(target *Update) *rbxdump.Root {
+	start := target
+	for start.Prev != nil {
+		start = start.Prev
+	}
+	patcher := diff.Patch{Root: &rbxdump.Root{}}
+	for update := start; ; update = update.Next {
+		for _, change := range update.Changes {
+			patcher.Patch([]diff.Action{change.Action})
+		}
+		if update == target {
+			break
+		}
+	}
+	return patcher.Root
+}
+
+// Fails if the dump of cursor differs from the replayed dump and the source
+// dump of update i.
+func checkRoll(t *testing.T, cursor *Cursor, hist *Root, i, seed, from int) {
+	t.Helper()
+	if cursor.Target != hist.Update[i] {
+		t.Fatalf("roll to %d: cursor at %s", i, cursor.Target.GUID)
+	}
+	for name, want := range map[string]*rbxdump.Root{
+		"replay": replay(hist.Update[i]),
+		"source": testDump(i, seed, from),
+	} {
+		if actions := (diff.Diff{Prev: cursor.Dump, Next: want}).Diff(); len(actions) > 0 {
+			t.Fatalf("roll to %d: dump differs from %s: %v", i, name, actions)
+		}
+	}
+}
+
+func TestCursorRollForward(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	var cursor Cursor
+	for i := range hist.Update {
+		if !cursor.Roll(hist.Update[i]) {
+			t.Fatalf("roll to %d failed", i)
+		}
+		checkRoll(t, &cursor, hist, i, 0, 0)
+	}
+	// Skip several updates at once.
+	cursor = Cursor{}
+	for _, i := range []int{1, 4, 9, 11} {
+		if !cursor.Roll(hist.Update[i]) {
+			t.Fatalf("roll to %d failed", i)
+		}
+		checkRoll(t, &cursor, hist, i, 0, 0)
+	}
+}
+
+func TestCursorRollBackward(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	for from := range hist.Update {
+		for to := 0; to < from; to++ {
+			var cursor Cursor
+			cursor.Roll(hist.Update[from])
+			if !cursor.Roll(hist.Update[to]) {
+				t.Fatalf("roll from %d to %d failed", from, to)
+			}
+			checkRoll(t, &cursor, hist, to, 0, 0)
+		}
+	}
+}
+
+func TestCursorRollOtherChain(t *testing.T) {
+	hist := testHistory(t, 0, 0)
+	other := testHistory(t, 0, 0)
+	var cursor Cursor
+	cursor.Roll(hist.Update[3])
+	if cursor.Roll(other.Update[5]) {
+		t.Fatal("expected roll to update of other chain to fail")
+	}
+	if cursor.Roll(nil) {
+		t.Fatal("expected roll to nil to fail")
+	}
+}
@@ -0,0 +1,239 @@
+package reflect
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/rbxver"
+	"github.com/robloxapi/roar/archive"
+	"github.com/robloxapi/roar/id"
+)
+
+// Indicates the kind of entity to which a metadata change applies.
+type Element string
+
+const (
+	ElementClass    Element = "Class"
+	ElementMember   Element = "Member"
+	ElementEnum     Element = "Enum"
+	ElementEnumItem Element = "EnumItem"
+)
+
+// Represents one unit of change to the metadata of an entity. Changes are
+// recorded per metadata field. An entity is considered added when its first
+// field is added, and removed when its last field is removed.
+type Change struct {
+	// Whether the field was added, removed, or changed.
+	Type diff.Type
+	// The kind of entity that has the field.
+	Element Element
+	// Name of the class or enum.
+	Primary string
+	// Name of the member or enum item. Empty for classes and enums.
+	Secondary string `json:",omitempty"`
+	// Name of the metadata field.
+	Field string
+	// The value before the change. Nil if the field was added.
+	Prev *Value `json:",omitempty"`
+	// The value after the change. Nil if the field was removed.
+	Next *Value `json:",omitempty"`
+}
+
+// Represents an update that caused a number of metadata changes.
+type Update struct {
+	// Time when the update occurred.
+	Date time.Time
+	// Version ID string (version-0123456789abcdef).
+	GUID string
+	// Version number.
+	Version rbxver.Version
+	// List of changes that occurred during the update.
+	Changes []Change
+}
+
+// Records changes to ReflectionMetadata across a number of builds.
+type History struct {
+	// List of all updates, ordered by date.
+	Update []*Update
+}
+
+// Returns a new History with initialized fields.
+func NewHistory() *History {
+	return &History{Update: []*Update{}}
+}
+
+// Appends an update derived from the given build and changes.
+func (h *History) AppendUpdate(build archive.Build, changes []Change) {
+	build.Version.Format = rbxver.Dot
+	h.Update = append(h.Update, &Update{
+		Date:    build.Date,
+		GUID:    build.GUID,
+		Version: build.Version,
+		Changes: changes,
+	})
+}
+
+// Returns whether two values are equivalent. Values are compared by their JSON
+// representation, so that decoded values compare equal to parsed values.
+func equalValue(a, b Value) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	ja, erra := json.Marshal(a.Value)
+	jb, errb := json.Marshal(b.Value)
+	if erra != nil || errb != nil {
+		return false
+	}
+	return bytes.Equal(ja, jb)
+}
+
+// Returns the sorted union of the keys of two maps.
+func unionKeys[K cmp.Ordered, V any](a, b map[K]V) []K {
+	keys := make([]K, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Appends the differences between two sets of metadata. template supplies the
+// identifying fields of each change.
+func diffMetadata(changes []Change, template Change, prev, next Metadata) []Change {
+	for _, field := range unionKeys(prev, next) {
+		p, pok := prev[field]
+		n, nok := next[field]
+		change := template
+		change.Field = field
+		switch {
+		case !pok:
+			change.Type = diff.Add
+			change.Next = &n
+		case !nok:
+			change.Type = diff.Remove
+			change.Prev = &p
+		case !equalValue(p, n):
+			change.Type = diff.Change
+			change.Prev = &p
+			change.Next = &n
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Returns the differences between two roots as a list of changes, ordered by
+// entity and field.
+func Diff(prev, next Root) (changes []Change) {
+	for _, class := range unionKeys(prev.Class, next.Class) {
+		p, n := prev.Class[class], next.Class[class]
+		changes = diffMetadata(changes, Change{
+			Element: ElementClass,
+			Primary: class,
+		}, p.Metadata, n.Metadata)
+		for _, member := range unionKeys(p.Member, n.Member) {
+			changes = diffMetadata(changes, Change{
+				Element:   ElementMember,
+				Primary:   class,
+				Secondary: member,
+			}, p.Member[member].Metadata, n.Member[member].Metadata)
+		}
+	}
+	for _, enum := range unionKeys(prev.Enum, next.Enum) {
+		p, n := prev.Enum[enum], next.Enum[enum]
+		changes = diffMetadata(changes, Change{
+			Element: ElementEnum,
+			Primary: enum,
+		}, p.Metadata, n.Metadata)
+		for _, item := range unionKeys(p.EnumItem, n.EnumItem) {
+			changes = diffMetadata(changes, Change{
+				Element:   ElementEnumItem,
+				Primary:   enum,
+				Secondary: item,
+			}, p.EnumItem[item].Metadata, n.EnumItem[item].Metadata)
+		}
+	}
+	return changes
+}
+
+// Sets or deletes field in m according to change. Returns the resulting map,
+// which may be newly allocated.
+func patchMetadata(m Metadata, change Change) Metadata {
+	if change.Type == diff.Remove || change.Next == nil {
+		delete(m, change.Field)
+		return m
+	}
+	if m == nil {
+		m = Metadata{}
+	}
+	m[change.Field] = *change.Next
+	return m
+}
+
+// Applies a list of changes to root. Entities left without metadata are
+// removed, matching the output of ParseReflectionMetadata.
+func Patch(root *Root, changes []Change) {
+	if root.Class == nil {
+		root.Class = map[id.Class]Class{}
+	}
+	if root.Enum == nil {
+		root.Enum = map[id.Enum]Enum{}
+	}
+	for _, change := range changes {
+		switch change.Element {
+		case ElementClass, ElementMember:
+			class := root.Class[change.Primary]
+			if change.Element == ElementClass {
+				class.Metadata = patchMetadata(class.Metadata, change)
+			} else {
+				if class.Member == nil {
+					class.Member = map[id.Member]Member{}
+				}
+				member := class.Member[change.Secondary]
+				member.Metadata = patchMetadata(member.Metadata, change)
+				if len(member.Metadata) == 0 {
+					delete(class.Member, change.Secondary)
+				} else {
+					class.Member[change.Secondary] = member
+				}
+			}
+			if len(class.Metadata) == 0 && len(class.Member) == 0 {
+				delete(root.Class, change.Primary)
+			} else {
+				root.Class[change.Primary] = class
+			}
+		case ElementEnum, ElementEnumItem:
+			enum := root.Enum[change.Primary]
+			if change.Element == ElementEnum {
+				enum.Metadata = patchMetadata(enum.Metadata, change)
+			} else {
+				if enum.EnumItem == nil {
+					enum.EnumItem = map[id.EnumItem]EnumItem{}
+				}
+				item := enum.EnumItem[change.Secondary]
+				item.Metadata = patchMetadata(item.Metadata, change)
+				if len(item.Metadata) == 0 {
+					delete(enum.EnumItem, change.Secondary)
+				} else {
+					enum.EnumItem[change.Secondary] = item
+				}
+			}
+			if len(enum.Metadata) == 0 && len(enum.EnumItem) == 0 {
+				delete(root.Enum, change.Primary)
+			} else {
+				root.Enum[change.Primary] = enum
+			}
+		}
+	}
+}
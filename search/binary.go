@@ -5,11 +5,15 @@ import (
 	"bytes"
 	"cmp"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"slices"
 
 	"github.com/robloxapi/rbxdump"
+	"github.com/robloxapi/roar/docs"
+	"github.com/robloxapi/roar/history"
 	"github.com/robloxapi/roar/id"
 	"github.com/robloxapi/roar/index"
 )
@@ -23,11 +27,28 @@ func keys[K cmp.Ordered, V any](m map[K]V) []K {
 	return ks
 }
 
-// A method to encode v to offset i with the row.
-type method func(row []byte, i, v int)
+// A method to encode and decode a value at offset i within a row.
+type method struct {
+	// Identifies the method within the header.
+	code uint8
+	// Encodes v to offset i within the row.
+	encode func(row []byte, i, v int)
+	// Decodes the value at offset i within the row. Returns false if the value
+	// is unset.
+	decode func(row []byte, i int) (v int, ok bool)
+	// Range of values that can be encoded. Values outside of the range would be
+	// truncated or confused with an unset value.
+	min, max int
+}
+
+// Returns whether v can be encoded by the method.
+func (m method) fits(v int) bool {
+	return m.min <= v && v <= m.max
+}
 
 // Represents the encoding of an entity field within a data table row.
 type field struct {
+	name   string // The name of the field, as written to the header.
 	method method // The method used to encode a value.
 	offset int    // The byte offset within the row.
 }
@@ -35,116 +56,284 @@ type field struct {
 // Encoding of various entity fields. Different fields may be encoded depending
 // on the entity type, such that each row is effectively a union of all entity
 // types.
+//
+// Offsets are canonical, assuming that string indices are 2 bytes wide. The
+// actual position of a field is determined by the layout of the database.
 var (
 	// Entity
 
-	_PRIMARY   = field{s2, 0} // Primary identifier
-	_SECONDARY = field{s2, 2} // Secondary identifier
-	_FLAGS     = field{f4, 4} // Entity flags
+	_PRIMARY   = field{"PRIMARY", s2, 0}   // Primary identifier
+	_SECONDARY = field{"SECONDARY", s2, 2} // Secondary identifier
+	_FLAGS     = field{"FLAGS", f4, 4}     // Entity flags
 
 	// Class
 
-	_CLASS_NAME   = field{s2, 0}  // class.Name
-	_SUPERCLASSES = field{n1, 8}  // Number of superclasses of class
-	_SUBCLASSES   = field{n2, 9}  // Number of subclasses of class
-	_MEMBERS      = field{n2, 11} // len(class.Members)
-	_ANCESTOR     = field{n1, 13} // Order index of superclass
-	_SUPERCLASS   = field{s2, 15} // Specific superclass of class
-	_SUBCLASS     = field{s2, 17} // Specific subclass of class
-	_MEM_CAT      = field{s2, 19} // class.MemoryCategory
+	_CLASS_NAME   = field{"CLASS_NAME", s2, 0}   // class.Name
+	_SUPERCLASSES = field{"SUPERCLASSES", n1, 8} // Number of superclasses of class
+	_SUBCLASSES   = field{"SUBCLASSES", n2, 9}   // Number of subclasses of class
+	_MEMBERS      = field{"MEMBERS", n2, 11}     // len(class.Members)
+	_ANCESTOR     = field{"ANCESTOR", n1, 13}    // Order index of superclass
+	_SUPERCLASS   = field{"SUPERCLASS", s2, 15}  // Specific superclass of class
+	_SUBCLASS     = field{"SUBCLASS", s2, 17}    // Specific subclass of class
+	_MEM_CAT      = field{"MEM_CAT", s2, 19}     // class.MemoryCategory
 
 	// Member (property, function, event, callback)
 
-	_MEMBER_NAME   = field{s2, 2}  // member.Name
-	_THREAD_SAFETY = field{e0, 13} // member.ThreadSafety
-	_SECURITY      = field{e1, 13} // member.Security
+	_MEMBER_NAME   = field{"MEMBER_NAME", s2, 2}    // member.Name
+	_THREAD_SAFETY = field{"THREAD_SAFETY", e0, 13} // member.ThreadSafety
+	_SECURITY      = field{"SECURITY", e1, 13}      // member.Security
 
 	// Property
 
-	_CAN_SAVE        = field{b1, 11} // property.CanSave
-	_CAN_LOAD        = field{b1, 12} // property.CanLoad
-	_READ_SECURITY   = field{e1, 13} // property.ReadSecurity
-	_WRITE_SECURITY  = field{e0, 14} // property.WriteSecurity
-	_VALUE_TYPE_CAT  = field{e1, 14} // property.ValueType.Category
-	_VALUE_TYPE_NAME = field{s2, 15} // property.ValueType.Name
-	_CATEGORY        = field{s2, 19} // property.Category
-	_DEFAULT         = field{s2, 21} // property.Default
+	_CAN_SAVE        = field{"CAN_SAVE", b1, 11}        // property.CanSave
+	_CAN_LOAD        = field{"CAN_LOAD", b1, 12}        // property.CanLoad
+	_READ_SECURITY   = field{"READ_SECURITY", e1, 13}   // property.ReadSecurity
+	_WRITE_SECURITY  = field{"WRITE_SECURITY", e0, 14}  // property.WriteSecurity
+	_VALUE_TYPE_CAT  = field{"VALUE_TYPE_CAT", e1, 14}  // property.ValueType.Category
+	_VALUE_TYPE_NAME = field{"VALUE_TYPE_NAME", s2, 15} // property.ValueType.Name
+	_CATEGORY        = field{"CATEGORY", s2, 19}        // property.Category
+	_DEFAULT         = field{"DEFAULT", s2, 21}         // property.Default
 
 	// Function, event, callback
 
-	_RETURNS          = field{n1, 8}  // len(member.ReturnType)
-	_PARAMETERS       = field{n2, 9}  // len(member.Parameters)
-	_PARAM_TYPE_OPT   = field{b1, 11} // member.Parameters[].Type.Optional
-	_RETURN_TYPE_OPT  = field{b1, 12} // member.ReturnType[].Optional
-	_PARAM_TYPE_CAT   = field{e0, 14} // member.Parameters[].Type.Category
-	_RETURN_TYPE_CAT  = field{e1, 14} // member.ReturnType[].Category
-	_RETURN_TYPE_NAME = field{s2, 15} // member.ReturnType[].Name
-	_PARAM_TYPE_NAME  = field{s2, 17} // member.Parameters[].Type.Name
-	_PARAM_NAME       = field{s2, 19} // member.Parameters[].Name
-	_PARAM_DEFAULT    = field{s2, 21} // member.Parameters[].Default (if Optional)
+	_RETURNS          = field{"RETURNS", n1, 8}           // len(member.ReturnType)
+	_PARAMETERS       = field{"PARAMETERS", n2, 9}        // len(member.Parameters)
+	_PARAM_TYPE_OPT   = field{"PARAM_TYPE_OPT", b1, 11}   // member.Parameters[].Type.Optional
+	_RETURN_TYPE_OPT  = field{"RETURN_TYPE_OPT", b1, 12}  // member.ReturnType[].Optional
+	_PARAM_TYPE_CAT   = field{"PARAM_TYPE_CAT", e0, 14}   // member.Parameters[].Type.Category
+	_RETURN_TYPE_CAT  = field{"RETURN_TYPE_CAT", e1, 14}  // member.ReturnType[].Category
+	_RETURN_TYPE_NAME = field{"RETURN_TYPE_NAME", s2, 15} // member.ReturnType[].Name
+	_PARAM_TYPE_NAME  = field{"PARAM_TYPE_NAME", s2, 17}  // member.Parameters[].Type.Name
+	_PARAM_NAME       = field{"PARAM_NAME", s2, 19}       // member.Parameters[].Name
+	_PARAM_DEFAULT    = field{"PARAM_DEFAULT", s2, 21}    // member.Parameters[].Default (if Optional)
 
 	// Enum
 
-	_ENUM_NAME  = field{s2, 0} // enum.Name
-	_ENUM_ITEMS = field{n2, 9} // len(enum.Items)
+	_ENUM_NAME  = field{"ENUM_NAME", s2, 0}  // enum.Name
+	_ENUM_ITEMS = field{"ENUM_ITEMS", n2, 9} // len(enum.Items)
 
 	// EnumItem
 
-	_ITEM_NAME    = field{s2, 2}  // enumitem.Name
-	_LEGACY_NAMES = field{n1, 8}  // len(enumitem.LegacyNames)
-	_ITEM_VALUE   = field{n4, 9}  // enumitem.Value
-	_LEGACY_NAME  = field{s2, 15} // enumitem.LegacyName[]
+	_ITEM_NAME    = field{"ITEM_NAME", s2, 2}    // enumitem.Name
+	_LEGACY_NAMES = field{"LEGACY_NAMES", n1, 8} // len(enumitem.LegacyNames)
+	_ITEM_VALUE   = field{"ITEM_VALUE", i4, 9}   // enumitem.Value
+	_LEGACY_NAME  = field{"LEGACY_NAME", s2, 15} // enumitem.LegacyName[]
 
 	// Type
 
-	_TYPE_NAME = field{s2, 0}  // type.Name
-	_TYPE_CAT  = field{e0, 14} // type.Category
+	_TYPE_NAME = field{"TYPE_NAME", s2, 0} // type.Name
+	_TYPE_CAT  = field{"TYPE_CAT", e0, 14} // type.Category
 )
 
+// Fields encoded by the rows of each data table, keyed by entity type. Tables
+// of unknown member types use memberFields.
+var tableFields = map[string][]field{
+	"Class": {
+		_CLASS_NAME, _FLAGS, _SUPERCLASSES, _SUBCLASSES, _MEMBERS, _ANCESTOR,
+		_SUPERCLASS, _SUBCLASS, _MEM_CAT,
+	},
+	"Property": {
+		_CLASS_NAME, _MEMBER_NAME, _FLAGS, _CAN_SAVE, _CAN_LOAD,
+		_READ_SECURITY, _THREAD_SAFETY, _VALUE_TYPE_CAT, _WRITE_SECURITY,
+		_VALUE_TYPE_NAME, _CATEGORY, _DEFAULT,
+	},
+	"Function": {
+		_CLASS_NAME, _MEMBER_NAME, _FLAGS, _RETURNS, _PARAMETERS, _SECURITY,
+		_THREAD_SAFETY, _RETURN_TYPE_OPT, _RETURN_TYPE_CAT, _RETURN_TYPE_NAME,
+		_PARAM_TYPE_OPT, _PARAM_TYPE_CAT, _PARAM_TYPE_NAME, _PARAM_NAME,
+		_PARAM_DEFAULT,
+	},
+	"Event": {
+		_CLASS_NAME, _MEMBER_NAME, _FLAGS, _PARAMETERS, _SECURITY,
+		_THREAD_SAFETY, _PARAM_TYPE_OPT, _PARAM_TYPE_CAT, _PARAM_TYPE_NAME,
+		_PARAM_NAME,
+	},
+	"Callback": {
+		_CLASS_NAME, _MEMBER_NAME, _FLAGS, _RETURNS, _PARAMETERS, _SECURITY,
+		_THREAD_SAFETY, _RETURN_TYPE_OPT, _RETURN_TYPE_CAT, _RETURN_TYPE_NAME,
+		_PARAM_TYPE_OPT, _PARAM_TYPE_CAT, _PARAM_TYPE_NAME, _PARAM_NAME,
+	},
+	"Enum": {
+		_ENUM_NAME, _FLAGS, _ENUM_ITEMS,
+	},
+	"EnumItem": {
+		_ENUM_NAME, _ITEM_NAME, _FLAGS, _LEGACY_NAMES, _ITEM_VALUE,
+		_LEGACY_NAME,
+	},
+	"Type": {
+		_TYPE_NAME, _FLAGS, _TYPE_CAT,
+	},
+}
+
+// Fields encoded by the rows of a member type that is otherwise unknown.
+var memberFields = []field{_CLASS_NAME, _MEMBER_NAME, _FLAGS}
+
+// Returns the fields encoded by the rows of the table for the given entity
+// type.
+func fieldsOf(typ string) []field {
+	if fields, ok := tableFields[typ]; ok {
+		return fields
+	}
+	return memberFields
+}
+
 // 4-bit enumeration packed into lower 4 bits.
-func e0(row []byte, i, v int) {
-	row[i] = (row[i] & 0b11110000) | byte((v&0b1111)<<0)
+var e0 = method{
+	code: 0,
+	encode: func(row []byte, i, v int) {
+		row[i] = (row[i] & 0b11110000) | byte((v&0b1111)<<0)
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		v := int(row[i]&0b00001111) >> 0
+		return v, v != 0xF
+	},
+	min: 0,
+	max: 0xE,
 }
 
 // 4-bit enumeration packed into upper 4 bits.
-func e1(row []byte, i, v int) {
-	row[i] = (row[i] & 0b00001111) | byte((v&0b1111)<<4)
+var e1 = method{
+	code: 1,
+	encode: func(row []byte, i, v int) {
+		row[i] = (row[i] & 0b00001111) | byte((v&0b1111)<<4)
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		v := int(row[i]&0b11110000) >> 4
+		return v, v != 0xF
+	},
+	min: 0,
+	max: 0xE,
 }
 
 // 8-bit enumeration. Value is an index of a prefabricated array of string
 // indices.
-func e2(row []byte, i, v int) {
-	row[i] = byte(v)
+var e2 = method{
+	code: 2,
+	encode: func(row []byte, i, v int) {
+		row[i] = byte(v)
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		return int(row[i]), row[i] != 0xFF
+	},
+	min: 0,
+	max: 0xFE,
 }
 
-// String. Value is an index of an array of strings.
-func s2(row []byte, i, v int) {
-	binary.LittleEndian.PutUint16(row[i:], uint16(v))
+// String. Value is an index of an array of strings, encoded with the given
+// number of bytes.
+func sN(width int) method {
+	unset := 1<<(8*width) - 1
+	return method{
+		code: 3,
+		encode: func(row []byte, i, v int) {
+			for j := range width {
+				row[i+j] = byte(v >> (8 * j))
+			}
+		},
+		decode: func(row []byte, i int) (int, bool) {
+			v := 0
+			for j := range width {
+				v |= int(row[i+j]) << (8 * j)
+			}
+			return v, v != unset
+		},
+		min: 0,
+		max: unset - 1,
+	}
 }
 
-// Flags. Value is a bit field.Bit representation is determined by header.
-func f4(row []byte, i, v int) {
-	binary.LittleEndian.PutUint32(row[i:], uint32(v))
+// String with 2-byte indices. Used by the canonical definition of fields.
+var s2 = sN(2)
+
+// Flags. Value is a bit field of the given number of bytes. Bit representation
+// is determined by header. Values are encoded from a bitset rather than an
+// integer, and decoding only indicates whether the field is set; individual
+// bits are decoded with layout.bit.
+func fN(width int) method {
+	return method{
+		code: 4,
+		decode: func(row []byte, i int) (int, bool) {
+			for _, b := range row[i : i+width] {
+				if b != 0xFF {
+					return 0, true
+				}
+			}
+			return 0, false
+		},
+	}
 }
 
+// Flags with 4 bytes. Used by the canonical definition of fields.
+var f4 = fN(4)
+
 // uint8.
-func n1(row []byte, i, v int) {
-	row[i] = byte(v)
+var n1 = method{
+	code: 5,
+	encode: func(row []byte, i, v int) {
+		row[i] = byte(v)
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		return int(row[i]), row[i] != 0xFF
+	},
+	min: 0,
+	max: 0xFE,
 }
 
 // uint16.
-func n2(row []byte, i, v int) {
-	binary.LittleEndian.PutUint16(row[i:], uint16(v))
+var n2 = method{
+	code: 6,
+	encode: func(row []byte, i, v int) {
+		binary.LittleEndian.PutUint16(row[i:], uint16(v))
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		v := binary.LittleEndian.Uint16(row[i:])
+		return int(v), v != 0xFFFF
+	},
+	min: 0,
+	max: 0xFFFE,
 }
 
 // uint32.
-func n4(row []byte, i, v int) {
-	binary.LittleEndian.PutUint32(row[i:], uint32(v))
+var n4 = method{
+	code: 7,
+	encode: func(row []byte, i, v int) {
+		binary.LittleEndian.PutUint32(row[i:], uint32(v))
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		v := binary.LittleEndian.Uint32(row[i:])
+		return int(v), v != 0xFFFFFFFF
+	},
+	min: 0,
+	max: 0xFFFFFFFE,
+}
+
+// int32, zigzag-encoded so that negative values are not confused with an unset
+// value.
+var i4 = method{
+	code: 9,
+	encode: func(row []byte, i, v int) {
+		binary.LittleEndian.PutUint32(row[i:], uint32(int32(v)<<1^int32(v)>>31))
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		v := binary.LittleEndian.Uint32(row[i:])
+		return int(int32(v>>1) ^ -int32(v&1)), v != 0xFFFFFFFF
+	},
+	// The encoding of math.MinInt32 is the unset value.
+	min: math.MinInt32 + 1,
+	max: math.MaxInt32,
 }
 
 // 8-bit Bool.
-func b1(row []byte, i int, v int) {
-	row[i] = byte(v)
+var b1 = method{
+	code: 8,
+	encode: func(row []byte, i, v int) {
+		row[i] = byte(v)
+	},
+	decode: func(row []byte, i int) (int, bool) {
+		return int(row[i]), row[i] != 0xFF
+	},
+	min: 0,
+	max: 1,
 }
 
 // Converts a bool to an integer. 1==true, 0==false.
@@ -157,29 +346,204 @@ func booli(v bool) int {
 
 type cell struct {
 	field
-	value int
+	value any // int, or bitset for flags.
 }
 
-type table struct {
-	rows int
-	buf  bytes.Buffer
+// Describes the positions of fields within a data table row, which depend on
+// the width of string indices and entity flags. Wider fields push subsequent
+// fields further into the row.
+type layout struct {
+	strWidth  int    // Number of bytes used by a string index.
+	str       method // Method used to encode string indices.
+	flagWidth int    // Number of bytes used by entity flags.
+	flags     method // Method used to encode entity flags.
 }
 
-// Writes a new row to the table. Unset cells are filled with ones.
-func (t *table) row(cells ...cell) {
-	t.rows++
-	// row := [24]byte{}
-	row := [23]byte{}
-	for i := range row {
-		row[i] = 0xFF
-	}
-	for _, cell := range cells {
-		if cell.method == nil {
+// Returns the layout with the narrowest string indices able to refer to the
+// given number of strings, and flags wide enough to hold the given number of
+// bits.
+func newLayout(strings, flagBits int) (layout, error) {
+	// Always leave at least one bit unused, so that set flags are never
+	// confused with unset flags.
+	flagWidth := max(4, flagBits/8+1)
+	for width := 2; width <= 4; width++ {
+		l := layoutOf(width, flagWidth)
+		if strings > l.str.max+1 {
 			continue
 		}
-		cell.method(row[:], cell.offset, cell.value)
+		if l.rowSize() > math.MaxUint8 {
+			return layout{}, fmt.Errorf("%d flags exceeds limit", flagBits)
+		}
+		return l, nil
+	}
+	return layout{}, fmt.Errorf("%d strings exceeds limit", strings)
+}
+
+// Returns the layout with string indices and flags of the given widths.
+func layoutOf(strWidth, flagWidth int) layout {
+	return layout{
+		strWidth:  strWidth,
+		str:       sN(strWidth),
+		flagWidth: flagWidth,
+		flags:     fN(flagWidth),
+	}
+}
+
+// Maps a canonical offset to an offset within the layout. A canonical row
+// consists of 2 strings, 4 bytes of flags, 7 bytes of other values, and 4
+// more strings.
+func (l layout) offset(i int) int {
+	w := l.strWidth
+	f := l.flagWidth
+	switch {
+	case i < 4:
+		return i / 2 * w
+	case i < 8:
+		return i - 4 + 2*w
+	case i < 15:
+		return i - 8 + 2*w + f
+	default:
+		return (i-15)/2*w + 2*w + f + 7
+	}
+}
+
+// Returns the size of a row within a data table.
+func (l layout) rowSize() int {
+	return l.offset(23)
+}
+
+// Returns f positioned according to the layout.
+func (l layout) resolve(f field) field {
+	if f.method.decode == nil {
+		return f
+	}
+	f.offset = l.offset(f.offset)
+	switch f.method.code {
+	case s2.code:
+		f.method = l.str
+	case f4.code:
+		f.method = l.flags
+	}
+	return f
+}
+
+// Returns whether bit n of the entity flags within row is set. Returns false
+// if the flags are unset.
+func (l layout) bit(row []byte, n int) (set, ok bool) {
+	f := l.resolve(_FLAGS)
+	if _, ok := f.method.decode(row, f.offset); !ok {
+		return false, false
+	}
+	if n/8 >= l.flagWidth {
+		return false, true
+	}
+	return row[f.offset+n/8]&(1<<(n%8)) != 0, true
+}
+
+// Identifies a file as a search database.
+const magic = "RSDB"
+
+// Version of the database format. Incremented whenever the layout changes
+// incompatibly.
+const version = 6
+
+// Encoding of the rows of the data tables of a database.
+type Format uint8
+
+const (
+	// Rows are stored as is, each with the same size. Fastest to decode.
+	FormatFixed Format = iota
+	// Each row is stored as a bitmap indicating which bytes of the row are
+	// set, followed by the set bytes. Unset bytes are all ones. Smaller, since
+	// most rows are sparse.
+	FormatCompact
+)
+
+// Returns data, consisting of rows of the given size, in the compact format.
+func compactRows(data []byte, size int) []byte {
+	var out []byte
+	for i := 0; i+size <= len(data); i += size {
+		row := data[i : i+size]
+		bitmap := len(out)
+		out = append(out, make([]byte, (size+7)/8)...)
+		for j, c := range row {
+			if c != 0xFF {
+				out[bitmap+j/8] |= 1 << (j % 8)
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// Flags indicating the optional sections present in the database.
+const (
+	// Index of documentation tokens, following the data tables. Maps each
+	// token to the rows of entities whose documentation contains the token.
+	sectionDocs = 1 << iota
+	// Index of updates, following the documentation index. Maps the rows of
+	// entities to the updates that added, changed, and removed them.
+	sectionHistory
+)
+
+// Rows of a data table. Rows are encoded only after all strings are known,
+// since the layout depends on the number of strings.
+type table struct {
+	rows [][]cell
+}
+
+// Adds a new row to the table. Returns the index of the row.
+func (t *table) row(cells ...cell) int {
+	t.rows = append(t.rows, cells)
+	return len(t.rows) - 1
+}
+
+// Encodes the rows of the table according to l. Unset cells are filled with
+// ones. Returns an error if a value cannot be encoded by its field.
+func (t *table) encode(l layout) ([]byte, error) {
+	size := l.rowSize()
+	buf := bytes.Repeat([]byte{0xFF}, len(t.rows)*size)
+	for i, cells := range t.rows {
+		row := buf[i*size : (i+1)*size]
+		for _, cell := range cells {
+			f := l.resolve(cell.field)
+			switch v := cell.value.(type) {
+			case int:
+				if f.method.encode == nil {
+					continue
+				}
+				if !f.method.fits(v) {
+					return nil, fmt.Errorf("row %d: value %d of field %s out of range", i, v, f.name)
+				}
+				f.method.encode(row, f.offset, v)
+			case bitset:
+				if f.method.code != f4.code || len(v) > l.flagWidth {
+					return nil, fmt.Errorf("row %d: flags of field %s out of range", i, f.name)
+				}
+				b := row[f.offset : f.offset+l.flagWidth]
+				clear(b)
+				copy(b, v)
+			}
+		}
+	}
+	return buf, nil
+}
+
+// Refers to a row of the data table of an entity type.
+type tableRow struct {
+	typ string
+	row int
+}
+
+// A bit field of arbitrary size. Bit n is the n%8th bit of byte n/8.
+type bitset []byte
+
+// Sets bit n, growing the bitset as needed.
+func (b *bitset) set(n int) {
+	for len(*b) <= n/8 {
+		*b = append(*b, 0)
 	}
-	t.buf.Write(row[:])
+	(*b)[n/8] |= 1 << (n % 8)
 }
 
 // Used to encode a number of strings to bit flags. The first bit is reserved
@@ -195,17 +559,22 @@ func (f flags) append(flag string) {
 	}
 }
 
+// Returns the number of bits required to hold all flags.
+func (f flags) len() int {
+	return len(f) + 1
+}
+
 // Produces a value containing the flags set according to the given arguments.
-func (f flags) bits(fielder rbxdump.Fielder, removed bool) (v int) {
+func (f flags) bits(fielder rbxdump.Fielder, removed bool) (v bitset) {
 	if removed {
-		v |= 1
+		v.set(0)
 	}
 	if fielder != nil {
 		fields := fielder.Fields(nil)
 		if tags, ok := fields["Tags"].(rbxdump.Tags); ok {
 			for _, tag := range tags {
 				if n, ok := f[tag]; ok {
-					v |= 1 << (n + 1)
+					v.set(n + 1)
 				}
 			}
 		}
@@ -240,9 +609,6 @@ func (b *blob) Append(s ...string) {
 // Returns the index of s in the blob. If s in not already in the blob, then it
 // is added.
 func (b *blob) Index(s string) int {
-	if len(s) >= 256 {
-		s = s[:256]
-	}
 	if v, ok := b.m[s]; ok {
 		return v
 	} else {
@@ -292,13 +658,23 @@ func visit[Map ~map[K]V, K cmp.Ordered, V any](m Map, visit func(k K, v V)) {
 	}
 }
 
-func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
+// Writes a search database generated from idx and dump to the file at path,
+// with data tables encoded in the given format.
+// If hist is not nil, then the database includes an index of the updates that
+// changed each entity. If doc is not nil, then the database includes an index
+// of the text of entity documentation.
+func WriteDB(path string, idx *index.Root, dump *rbxdump.Root, hist *history.Root, doc *docs.Root, format Format) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	return Encode(f, idx, dump, hist, doc, format)
+}
 
+// Encodes a search database generated from idx, dump, and optionally hist and
+// doc to w, with data tables encoded in the given format.
+func Encode(out io.Writer, idx *index.Root, dump *rbxdump.Root, hist *history.Root, doc *docs.Root, format Format) error {
 	// Initialize blob.
 	b := blob{}
 	b.Append("")
@@ -338,10 +714,8 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 	})
 
 	safeIndices, safeIndex, _ := enumerate(dump, &b, func(uniq map[string]struct{}) {
-		visitSecurities(dump, func(sec string) {
-			visitSafeties(dump, func(safe string) {
-				uniq[safe] = struct{}{}
-			})
+		visitSafeties(dump, func(safe string) {
+			uniq[safe] = struct{}{}
 		})
 	})
 
@@ -351,18 +725,32 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 		})
 	})
 
+	// Add field names used by table descriptors.
+	for _, typ := range types {
+		for _, f := range fieldsOf(typ) {
+			b.Append(f.name)
+		}
+	}
+
 	// Generate tables per entity type.
 	typeTables := make(map[string]*table, len(types))
 	for _, typ := range types {
 		typeTables[typ] = &table{}
 	}
+	docIdx := docIndex{}
+	var histIdx *historyIndex
+	var objects history.Object
+	if hist != nil && len(hist.Update) > 0 {
+		histIdx = newHistoryIndex(hist)
+		objects = hist.Object
+	}
 	visit(idx.Class, func(k id.Class, i *index.Class) {
 		d := dump.Classes[k]
 		if d == nil {
 			return
 		}
 
-		typeTables["Class"].row(
+		row := typeTables["Class"].row(
 			cell{_CLASS_NAME, b.Index(k)},
 			cell{_FLAGS, flags.bits(d, i.Removed)},
 			cell{_SUPERCLASSES, len(i.Superclasses)},
@@ -370,6 +758,8 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 			cell{_MEMBERS, len(idx.Member[k])},
 			cell{_MEM_CAT, b.Index(d.MemoryCategory)},
 		)
+		docIdx.add("Class", row, classDoc(doc, k))
+		histIdx.add("Class", row, objects.Class[k])
 		for i, sup := range i.Superclasses {
 			typeTables["Class"].row(
 				cell{_CLASS_NAME, b.Index(k)},
@@ -388,9 +778,10 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 			if d == nil {
 				return
 			}
+			var row int
 			switch d := d.(type) {
 			case *rbxdump.Property:
-				typeTables[d.MemberType()].row(
+				row = typeTables[d.MemberType()].row(
 					cell{_CLASS_NAME, b.Index(i.Class)},
 					cell{_MEMBER_NAME, b.Index(k)},
 					cell{_FLAGS, flags.bits(d, i.Removed)},
@@ -405,7 +796,7 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 					cell{_DEFAULT, b.Index(d.Default)},
 				)
 			case *rbxdump.Function:
-				typeTables[d.MemberType()].row(
+				row = typeTables[d.MemberType()].row(
 					cell{_CLASS_NAME, b.Index(i.Class)},
 					cell{_MEMBER_NAME, b.Index(k)},
 					cell{_FLAGS, flags.bits(d, i.Removed)},
@@ -435,7 +826,7 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 					)
 				}
 			case *rbxdump.Event:
-				typeTables[d.MemberType()].row(
+				row = typeTables[d.MemberType()].row(
 					cell{_CLASS_NAME, b.Index(i.Class)},
 					cell{_MEMBER_NAME, b.Index(k)},
 					cell{_FLAGS, flags.bits(d, i.Removed)},
@@ -454,7 +845,7 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 					)
 				}
 			case *rbxdump.Callback:
-				typeTables[d.MemberType()].row(
+				row = typeTables[d.MemberType()].row(
 					cell{_CLASS_NAME, b.Index(i.Class)},
 					cell{_MEMBER_NAME, b.Index(k)},
 					cell{_FLAGS, flags.bits(d, i.Removed)},
@@ -484,12 +875,14 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 				}
 			default:
 				// Unknown member type.
-				typeTables[d.MemberType()].row(
+				row = typeTables[d.MemberType()].row(
 					cell{_CLASS_NAME, b.Index(i.Class)},
 					cell{_MEMBER_NAME, b.Index(k)},
 					cell{_FLAGS, flags.bits(d, i.Removed)},
 				)
 			}
+			docIdx.add(d.MemberType(), row, memberDoc(doc, i.Class, k))
+			histIdx.add(d.MemberType(), row, objects.Member[id.MemberID{Class: i.Class, Member: k}])
 		})
 	})
 	visit(idx.Enum, func(k id.Enum, i *index.Enum) {
@@ -497,23 +890,27 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 		if d == nil {
 			return
 		}
-		typeTables["Enum"].row(
+		row := typeTables["Enum"].row(
 			cell{_ENUM_NAME, b.Index(k)},
 			cell{_FLAGS, flags.bits(d, i.Removed)},
 			cell{_ENUM_ITEMS, len(idx.EnumItem[k])},
 		)
+		docIdx.add("Enum", row, enumDoc(doc, k))
+		histIdx.add("Enum", row, objects.Enum[k])
 		visit(idx.EnumItem[k], func(k id.EnumItem, i *index.EnumItem) {
 			d := d.Items[k]
 			if d == nil {
 				return
 			}
-			typeTables["EnumItem"].row(
+			row := typeTables["EnumItem"].row(
 				cell{_ENUM_NAME, b.Index(i.Enum)},
 				cell{_ITEM_NAME, b.Index(k)},
 				cell{_FLAGS, flags.bits(d, i.Removed)},
 				cell{_LEGACY_NAMES, len(d.LegacyNames)},
 				cell{_ITEM_VALUE, d.Value},
 			)
+			docIdx.add("EnumItem", row, enumItemDoc(doc, i.Enum, k))
+			histIdx.add("EnumItem", row, objects.EnumItem[id.EnumItemID{Enum: i.Enum, EnumItem: k}])
 			for _, name := range d.LegacyNames {
 				typeTables["EnumItem"].row(
 					cell{_ENUM_NAME, b.Index(i.Enum)},
@@ -524,52 +921,148 @@ func WriteDB(path string, idx *index.Root, dump *rbxdump.Root) error {
 		})
 	})
 	visit(idx.Type, func(k id.Type, i *index.Type) {
-		typeTables["Type"].row(
+		row := typeTables["Type"].row(
 			cell{_TYPE_NAME, b.Index(k)},
 			cell{_FLAGS, flags.bits(nil, i.Removed)},
 			cell{_TYPE_CAT, catIndex[i.Category]},
 		)
+		docIdx.add("Type", row, typeDoc(doc, k))
 	})
 
-	// Write data.
-	w := newWriter(f)
-	defer w.Flush()
+	// Add documentation tokens, and determine the global index of the first
+	// row of each table.
+	tokens := keys(docIdx)
+	b.Append(tokens...)
+	var sections int
+	if len(tokens) > 0 {
+		sections |= sectionDocs
+	}
+	if histIdx != nil {
+		sections |= sectionHistory
+		for _, update := range hist.Update {
+			b.Append(update.GUID, update.Version.String())
+			if t := update.Date.Unix(); t < 0 || t > math.MaxUint32 {
+				return fmt.Errorf("update %s: date %s out of range", update.GUID, update.Date)
+			}
+		}
+	}
+	bases := make(map[string]int, len(types))
+	for i, typ := range types {
+		if i > 0 {
+			prev := types[i-1]
+			bases[typ] = bases[prev] + len(typeTables[prev].rows)
+		}
+	}
+
+	// Determine layout from the final number of strings.
+	l, err := newLayout(b.Count(), flags.len())
+	if err != nil {
+		return err
+	}
+	if b.Len() > math.MaxUint32 {
+		return fmt.Errorf("string data of %d bytes exceeds limit", b.Len())
+	}
+	tableData := make([][]byte, len(types))
+	for i, typ := range types {
+		t := typeTables[typ]
+		if len(t.rows) > math.MaxUint32 {
+			return fmt.Errorf("%s table: %d rows exceeds limit", typ, len(t.rows))
+		}
+		if tableData[i], err = t.encode(l); err != nil {
+			return fmt.Errorf("%s table: %w", typ, err)
+		}
+		if format == FormatCompact {
+			tableData[i] = compactRows(tableData[i], l.rowSize())
+		}
+	}
 
-	w.u16(b.Count())
-	w.u24(b.Len())
+	// Write data.
+	w := newWriter(out)
+
+	w.b([]byte(magic))
+	w.u8(version)
+	w.u8(l.rowSize())
+	w.u8(l.strWidth)
+	w.u8(l.flagWidth)
+	w.u8(sections)
+	w.u8(int(format))
+	w.u32(b.Count())
+	w.u32(b.Len())
 	w.u8(len(typeIndices))
-	w.u8(len(tagIndices))
+	w.u16(len(tagIndices))
 	w.u8(len(secIndices))
 	w.u8(len(safeIndices))
 	w.u8(len(catIndices))
 	for _, typ := range types {
-		w.u16(typeTables[typ].rows)
+		w.u32(len(typeTables[typ].rows))
 	}
 
 	for _, size := range b.i {
-		w.u8(size)
+		w.uvarint(size)
 	}
 	w.b(b.b.Bytes())
 	for _, typ := range typeIndices {
-		w.u8(typ)
+		w.uN(typ, l.strWidth)
 	}
 	for _, tag := range tagIndices {
-		w.u8(tag)
+		w.uN(tag, l.strWidth)
 	}
 	for _, sec := range secIndices {
-		w.u8(sec)
+		w.uN(sec, l.strWidth)
 	}
 	for _, safe := range safeIndices {
-		w.u8(safe)
+		w.uN(safe, l.strWidth)
 	}
 	for _, cat := range catIndices {
-		w.u8(cat)
+		w.uN(cat, l.strWidth)
 	}
 	for _, typ := range types {
-		w.b(typeTables[typ].buf.Bytes())
+		fields := fieldsOf(typ)
+		w.u8(len(fields))
+		for _, f := range fields {
+			f = l.resolve(f)
+			w.uN(b.Index(f.name), l.strWidth)
+			w.u8(int(f.method.code))
+			w.u8(f.offset)
+		}
+	}
+	for _, data := range tableData {
+		w.b(data)
+	}
+	if sections&sectionDocs != 0 {
+		w.u32(len(tokens))
+		for _, token := range tokens {
+			rows := docIdx.rows(token, bases)
+			w.uN(b.Index(token), l.strWidth)
+			w.uvarint(len(rows))
+			prev := 0
+			for _, row := range rows {
+				w.uvarint(row - prev)
+				prev = row
+			}
+		}
+	}
+	if sections&sectionHistory != 0 {
+		w.u32(len(hist.Update))
+		for _, update := range hist.Update {
+			w.uN(b.Index(update.GUID), l.strWidth)
+			w.uN(b.Index(update.Version.String()), l.strWidth)
+			w.u32(int(update.Date.Unix()))
+		}
+		rows, hists := histIdx.entries(bases)
+		w.u32(len(rows))
+		prev := 0
+		for i, row := range rows {
+			w.uvarint(row - prev)
+			prev = row
+			// Offset indices by one, so that no update is encoded as zero.
+			w.uvarint(hists[i].added + 1)
+			w.uvarint(hists[i].changed + 1)
+			w.uvarint(hists[i].removed + 1)
+		}
 	}
 
-	return nil
+	return w.Flush()
 }
 
 // Wrapper for encoding various types.
@@ -595,18 +1088,24 @@ func (w *writer) u16(v int) {
 	w.w.Write(b[:])
 }
 
-func (w *writer) u24(v int) {
-	var b [4]byte
-	binary.LittleEndian.PutUint32(b[:], uint32(v))
-	w.w.Write(b[:3])
-}
-
 func (w *writer) u32(v int) {
 	var b [4]byte
 	binary.LittleEndian.PutUint32(b[:], uint32(v))
 	w.w.Write(b[:])
 }
 
+// Writes v as an unsigned integer of n bytes.
+func (w *writer) uN(v, n int) {
+	for i := range n {
+		w.w.WriteByte(byte(v >> (8 * i)))
+	}
+}
+
+// Writes v as a variable-length unsigned integer.
+func (w *writer) uvarint(v int) {
+	w.w.Write(binary.AppendUvarint(nil, uint64(v)))
+}
+
 func (w *writer) b(v []byte) {
 	w.w.Write(v)
 }
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/robloxapi/roar/docs"
+)
+
+// Score of a row matching a documentation token. Less than any positive score
+// of a fuzzy match, so that documentation matches are sorted after name
+// matches.
+const docScore = 0.5
+
+// Tokens that are too common to be useful for searching.
+var stopWords = map[string]bool{
+	"and": true, "are": true, "but": true, "can": true, "for": true,
+	"from": true, "has": true, "have": true, "into": true, "its": true,
+	"not": true, "that": true, "the": true, "their": true, "then": true,
+	"this": true, "was": true, "when": true, "which": true, "will": true,
+	"with": true, "you": true, "your": true,
+}
+
+// Splits the rendered HTML text of documentation into lowercase tokens. Markup
+// is removed, and tokens consist of letters and digits. Short tokens and stop
+// words are excluded.
+func tokenize(text string, visit func(token string)) {
+	// Remove tags.
+	var b strings.Builder
+	inTag := false
+	for _, c := range text {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+			b.WriteByte(' ')
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	fields := strings.FieldsFunc(html.UnescapeString(b.String()), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for _, token := range fields {
+		token = strings.ToLower(token)
+		if len(token) < 3 || stopWords[token] {
+			continue
+		}
+		visit(token)
+	}
+}
+
+// Maps tokens of entity documentation to the main rows of the entities.
+type docIndex map[string][]tableRow
+
+// Adds the tokens of doc for the entity at the given row.
+func (x docIndex) add(typ string, row int, doc *docs.Doc) {
+	if doc == nil {
+		return
+	}
+	seen := map[string]bool{}
+	for _, text := range []string{doc.Summary, doc.Description, doc.DeprecationMessage} {
+		tokenize(text, func(token string) {
+			if seen[token] {
+				return
+			}
+			seen[token] = true
+			x[token] = append(x[token], tableRow{typ, row})
+		})
+	}
+}
+
+// Returns the postings of token as sorted global row indices, given the index
+// of the first row of each table.
+func (x docIndex) rows(token string, bases map[string]int) []int {
+	rows := make([]int, 0, len(x[token]))
+	for _, r := range x[token] {
+		rows = append(rows, bases[r.typ]+r.row)
+	}
+	slices.Sort(rows)
+	return rows
+}
+
+// Returns the documentation of a class, or nil if unavailable.
+func classDoc(root *docs.Root, class string) *docs.Doc {
+	if root == nil || root.Class[class] == nil {
+		return nil
+	}
+	return &root.Class[class].Doc
+}
+
+// Returns the documentation of a member, or nil if unavailable.
+func memberDoc(root *docs.Root, class, member string) *docs.Doc {
+	if root == nil || root.Member[class][member] == nil {
+		return nil
+	}
+	return &root.Member[class][member].Doc
+}
+
+// Returns the documentation of an enum, or nil if unavailable.
+func enumDoc(root *docs.Root, enum string) *docs.Doc {
+	if root == nil || root.Enum[enum] == nil {
+		return nil
+	}
+	return &root.Enum[enum].Doc
+}
+
+// Returns the documentation of an enum item, or nil if unavailable.
+func enumItemDoc(root *docs.Root, enum, item string) *docs.Doc {
+	if root == nil || root.EnumItem[enum][item] == nil {
+		return nil
+	}
+	return &root.EnumItem[enum][item].Doc
+}
+
+// Returns the documentation of a type, or nil if unavailable.
+func typeDoc(root *docs.Root, typ string) *docs.Doc {
+	if root == nil || root.Type[typ] == nil {
+		return nil
+	}
+	return &root.Type[typ].Doc
+}
+
+// Matches rows of the given types whose entity has documentation containing a
+// token.
+type exprDoc struct {
+	types []string
+	token string
+}
+
+func (e exprDoc) score(db *DB, r row) float64 {
+	if !slices.Contains(e.types, r.typ) || db.docs == nil {
+		return 0
+	}
+	if _, ok := slices.BinarySearch(db.docs[e.token], r.index); ok {
+		return docScore
+	}
+	return -1
+}
+
+func (e exprDoc) appendTypes(types []string) []string {
+	return append(types, e.types...)
+}
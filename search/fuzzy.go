@@ -0,0 +1,117 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Port of the fuzzy matching algorithm used by the site (fuzzy.js), originally
+// by Forrest Smith.
+//
+// Returns whether each character in pattern is found sequentially within str,
+// along with a score, where higher is a better match. The score has no
+// intrinsic meaning, and can only be compared with scores of the same pattern.
+func fuzzyMatch(pattern, str string) (matched bool, score int) {
+	const (
+		perfectBonus            = 100 // Bonus for perfect, case-insensitive matches.
+		adjacencyBonus          = 5   // Bonus for adjacent matches.
+		separatorBonus          = 10  // Bonus if match occurs after a separator.
+		camelBonus              = 10  // Bonus if match is uppercase and prev is lower.
+		leadingLetterPenalty    = -3  // Penalty applied for every letter in str before the first match.
+		maxLeadingLetterPenalty = -9  // Maximum penalty for leading letters.
+		unmatchedLetterPenalty  = -1  // Penalty for every letter that doesn't match.
+	)
+
+	p := []rune(pattern)
+	patternIdx := 0
+	prevMatched := false
+	prevLower := false
+	prevSeparator := true // True so that first letter match gets separator bonus.
+
+	// Use "best" matched letter if multiple string letters match the pattern.
+	hasBest := false
+	var bestLower rune
+	bestLetterScore := 0
+
+	for strIdx, strChar := range []rune(str) {
+		hasPattern := patternIdx != len(p)
+		var patternLower rune
+		if hasPattern {
+			patternLower = unicode.ToLower(p[patternIdx])
+		}
+		strLower := unicode.ToLower(strChar)
+		strUpper := unicode.ToUpper(strChar)
+
+		nextMatch := hasPattern && patternLower == strLower
+		rematch := hasBest && bestLower == strLower
+
+		advanced := nextMatch && hasBest
+		patternRepeat := hasBest && hasPattern && bestLower == patternLower
+		if advanced || patternRepeat {
+			score += bestLetterScore
+			hasBest = false
+			bestLetterScore = 0
+		}
+
+		if nextMatch || rematch {
+			newScore := 0
+
+			// Apply penalty for each letter before the first pattern match.
+			if patternIdx == 0 {
+				score += max(strIdx*leadingLetterPenalty, maxLeadingLetterPenalty)
+			}
+
+			// Apply bonus for consecutive matches.
+			if prevMatched {
+				newScore += adjacencyBonus
+			}
+
+			// Apply bonus for matches after a separator.
+			if prevSeparator {
+				newScore += separatorBonus
+			}
+
+			// Apply bonus across camel case boundaries.
+			if prevLower && strChar == strUpper && strLower != strUpper {
+				newScore += camelBonus
+			}
+
+			// Update pattern index if the next pattern letter was matched.
+			if nextMatch {
+				patternIdx++
+			}
+
+			// Update best letter in str, which may be for a "next" letter or a
+			// "rematch".
+			if newScore >= bestLetterScore {
+				// Apply penalty for now skipped letter.
+				if hasBest {
+					score += unmatchedLetterPenalty
+				}
+				hasBest = true
+				bestLower = strLower
+				bestLetterScore = newScore
+			}
+
+			prevMatched = true
+		} else {
+			score += unmatchedLetterPenalty
+			prevMatched = false
+		}
+
+		prevLower = strChar == strLower && strLower != strUpper
+		prevSeparator = strChar == '_' || strChar == ' '
+	}
+
+	// Apply score for last match.
+	if hasBest {
+		score += bestLetterScore
+	}
+
+	// Apply bonus for perfect match.
+	if strings.ToLower(pattern) == strings.ToLower(str) {
+		score += perfectBonus
+	}
+
+	return patternIdx == len(p), score
+}
@@ -0,0 +1,265 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/roar/history"
+)
+
+// An update of the API, as recorded by the history index of a database.
+type Update struct {
+	GUID    string
+	Version string
+	Date    time.Time
+}
+
+// Returns the GUID of the update.
+func (u Update) String() string {
+	return u.GUID
+}
+
+// Returns the number of days between the Unix epoch and the date of the
+// update.
+func (u Update) days() float64 {
+	return math.Floor(float64(u.Date.Unix()) / 86400)
+}
+
+// Indices of the updates that changed an entity. An index of -1 indicates that
+// there is no such update.
+type entityHistory struct {
+	added   int // First update that added the entity.
+	changed int // Last update that changed the entity in any way.
+	removed int // Update that removed the entity, if currently removed.
+}
+
+// Returns the history of an entity from its changes, given the index of each
+// update.
+func newEntityHistory(changes []*history.Change, updates map[*history.Update]int) entityHistory {
+	h := entityHistory{added: -1, changed: -1, removed: -1}
+	for _, change := range changes {
+		u, ok := updates[change.Update]
+		if !ok {
+			continue
+		}
+		switch change.Action.Type {
+		case diff.Add:
+			if h.added < 0 {
+				h.added = u
+			}
+			h.removed = -1
+		case diff.Remove:
+			h.removed = u
+		}
+		h.changed = max(h.changed, u)
+	}
+	return h
+}
+
+// Maps the main rows of entities to the updates that changed them.
+type historyIndex struct {
+	updates map[*history.Update]int
+	rows    map[tableRow]entityHistory
+}
+
+// Returns an index of the updates of hist.
+func newHistoryIndex(hist *history.Root) *historyIndex {
+	x := historyIndex{
+		updates: make(map[*history.Update]int, len(hist.Update)),
+		rows:    map[tableRow]entityHistory{},
+	}
+	for i, update := range hist.Update {
+		x.updates[update] = i
+	}
+	return &x
+}
+
+// Adds the history of the entity at the given row, derived from its changes.
+// Does nothing if x is nil.
+func (x *historyIndex) add(typ string, row int, changes []*history.Change) {
+	if x == nil || len(changes) == 0 {
+		return
+	}
+	x.rows[tableRow{typ, row}] = newEntityHistory(changes, x.updates)
+}
+
+// Returns the global row indices of the index in ascending order, given the
+// index of the first row of each table, along with the history of each row.
+func (x *historyIndex) entries(bases map[string]int) (rows []int, hists []entityHistory) {
+	m := make(map[int]entityHistory, len(x.rows))
+	for r, h := range x.rows {
+		m[bases[r.typ]+r.row] = h
+	}
+	rows = keys(m)
+	hists = make([]entityHistory, len(rows))
+	for i, row := range rows {
+		hists[i] = m[row]
+	}
+	return rows, hists
+}
+
+// Returns the update referred to by the history column c of the row. Returns
+// false if the database has no history, or the entity has no such update.
+func (db *DB) update(r row, c column) (v any, ok bool) {
+	h, ok := db.history[r.index]
+	if !ok {
+		return nil, false
+	}
+	var i int
+	switch c.kind {
+	case colAdded:
+		i = h.added
+	case colChanged:
+		i = h.changed
+	case colRemovedIn:
+		i = h.removed
+	}
+	if i < 0 || i >= len(db.Updates) {
+		return nil, false
+	}
+	return db.Updates[i], true
+}
+
+// Decodes the history index into db.
+func (r *reader) history(db *DB, width int) error {
+	n := r.u32()
+	db.Updates = make([]Update, 0, min(n, len(r.b)))
+	for range n {
+		guid := r.uN(width)
+		version := r.uN(width)
+		date := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if guid >= len(db.Strings) || version >= len(db.Strings) {
+			return fmt.Errorf("update %d: string index out of range", len(db.Updates))
+		}
+		db.Updates = append(db.Updates, Update{
+			GUID:    db.Strings[guid],
+			Version: db.Strings[version],
+			Date:    time.Unix(int64(date), 0).UTC(),
+		})
+	}
+
+	rows := 0
+	for _, table := range db.tables {
+		rows += len(table) / db.rowSize
+	}
+	n = r.u32()
+	db.history = make(map[int]entityHistory, min(n, rows))
+	row := 0
+	for range n {
+		row += r.uvarint()
+		// Indices are offset by one, so that zero indicates no update.
+		h := entityHistory{
+			added:   r.uvarint() - 1,
+			changed: r.uvarint() - 1,
+			removed: r.uvarint() - 1,
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if row >= rows {
+			return fmt.Errorf("row index %d out of range", row)
+		}
+		db.history[row] = h
+	}
+	return r.err
+}
+
+// Returns the number of days between the Unix epoch and the given date.
+func dateDays(year, month, day int) float64 {
+	return float64(time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// Matches an update that occurred between the given days, inclusive.
+func matchDays(lower, upper float64) matcher {
+	return func(v any) float64 {
+		u, ok := v.(Update)
+		if !ok {
+			return -1
+		}
+		if d := u.days(); lower <= d && d <= upper {
+			return 1
+		}
+		return -1
+	}
+}
+
+// Matches an update with the given GUID, ignoring case.
+func matchGUID(guid string) matcher {
+	return func(v any) float64 {
+		if u, ok := v.(Update); ok && strings.EqualFold(u.GUID, guid) {
+			return 1
+		}
+		return -1
+	}
+}
+
+// Parses an update component, which is a GUID, a date of the form
+// "YYYY-MM-DD", a range of dates, or a comparison against a date.
+func (p *parser) updateExpr() (matcher, bool) {
+	if p.lit("*") {
+		return matchTrue(), true
+	}
+	i := p.i
+	if p.litFold("version-") {
+		if w, ok := p.wordToken(); ok {
+			return matchGUID("version-" + w), true
+		}
+		p.i = i
+	}
+	if lower, ok := p.date(); ok && p.lit("..") {
+		if upper, ok := p.date(); ok {
+			return matchDays(lower, upper), true
+		}
+	}
+	p.i = i
+	ops := []string{"<=", "<", ">=", ">"}
+	op := ""
+	if j := slices.IndexFunc(ops, p.lit); j >= 0 {
+		op = ops[j]
+	}
+	d, ok := p.date()
+	if !ok {
+		p.i = i
+		return nil, false
+	}
+	inf := math.Inf(1)
+	switch op {
+	case "<=":
+		return matchDays(-inf, d), true
+	case "<":
+		return matchDays(-inf, d-1), true
+	case ">=":
+		return matchDays(d, inf), true
+	case ">":
+		return matchDays(d+1, inf), true
+	}
+	return matchDays(d, d), true
+}
+
+// Parses a date of the form "YYYY-MM-DD", returning the number of days since
+// the Unix epoch.
+func (p *parser) date() (days float64, ok bool) {
+	const layout = "0000-00-00"
+	if len(p.s)-p.i < len(layout) {
+		return 0, false
+	}
+	s := p.s[p.i : p.i+len(layout)]
+	for i, c := range []byte(layout) {
+		if c == '0' && !('0' <= s[i] && s[i] <= '9') || c == '-' && s[i] != '-' {
+			return 0, false
+		}
+	}
+	year, _ := strconv.Atoi(s[0:4])
+	month, _ := strconv.Atoi(s[5:7])
+	day, _ := strconv.Atoi(s[8:10])
+	p.i += len(layout)
+	return dateDays(year, month, day), true
+}
@@ -0,0 +1,923 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A parsed search query. The syntax of a query is described by the site's
+// search cheat sheet (site/content/search.md), and matches the grammar
+// implemented by the site (site/assets/js/query.js).
+type Query struct {
+	// Logical expression of selectors. Nil if the query has no selectors.
+	expr expr
+	// List selectors, in the order they appear.
+	list []list
+	// Additional results to be included unconditionally.
+	messages []string
+	// Maximum number of displayed results, if set.
+	limit    float64
+	hasLimit bool
+}
+
+// Number of results displayed when the query does not specify a limit.
+const defaultLimit = 50
+
+// Returns the leading portion of results that should be displayed according to
+// the query's limit.
+func (q *Query) Truncate(results []Result) []Result {
+	n := float64(defaultLimit)
+	if q.hasLimit {
+		n = q.limit
+	}
+	if math.IsNaN(n) || n <= 0 {
+		return results[:0]
+	}
+	if n >= float64(len(results)) {
+		return results
+	}
+	return results[:int(math.Ceil(n))]
+}
+
+// Kind of value listed by a list selector.
+type listKind int
+
+const (
+	listValues listKind = iota // Values of a field.
+	listKinds                  // Entity types.
+	listTags                   // Entity tags.
+	listFields                 // Names of fields.
+)
+
+// A list selector.
+type list struct {
+	kind  listKind
+	col   column
+	types []string
+}
+
+// Kind of value decoded by a column.
+type columnKind int
+
+const (
+	colString    columnKind = iota // Index of a string.
+	colNumber                      // Integer.
+	colBool                        // Boolean.
+	colRemoved                     // Removed bit of entity flags.
+	colSecurity                    // Index of a security context.
+	colSafety                      // Index of a thread safety level.
+	colCategory                    // Index of a type category.
+	colAdded                       // Update that added the entity.
+	colChanged                     // Update that last changed the entity.
+	colRemovedIn                   // Update that removed the entity.
+)
+
+// Describes how to interpret a field of a row.
+type column struct {
+	field field
+	kind  columnKind
+}
+
+var (
+	colPrimary   = column{_PRIMARY, colString}
+	colSecondary = column{_SECONDARY, colString}
+)
+
+// Kind of value component expected by a field selector.
+type valueKind int
+
+const (
+	valueString   valueKind = iota // String component.
+	valueNumber                    // Number component.
+	valueBool                      // Bool component.
+	valueDefault                   // Number, or otherwise string component.
+	valueKindName                  // Word naming an entity kind.
+	valueTag                       // Word naming a tag.
+	valueUpdate                    // Update GUID or date.
+)
+
+// Describes a field selector of the form "name:value", along with its list
+// selector, "name:".
+type selector struct {
+	name  string
+	value valueKind
+	col   column
+	// Entity types matched by the field selector.
+	types []string
+	// Entity types listed by the list selector.
+	list []string
+	// Additional column matched for property entities.
+	property *column
+}
+
+// Groups of entity types.
+type kinds struct {
+	all       []string
+	primary   []string
+	members   []string
+	secondary []string
+}
+
+func (db *DB) kinds() (k kinds) {
+	k.all = db.EntityTypes
+	k.primary = []string{"Class", "Enum", "Type"}
+	for _, typ := range db.EntityTypes {
+		switch typ {
+		case "Class", "Enum", "EnumItem", "Type":
+		default:
+			k.members = append(k.members, typ)
+		}
+	}
+	k.secondary = append(slices.Clip(k.members), "EnumItem")
+	return k
+}
+
+// Returns the field selectors, in the order their fields are listed.
+func selectors(k kinds) []selector {
+	class := []string{"Class"}
+	property := []string{"Property"}
+	fecs := []string{"Function", "Event", "Callback"}
+	fcs := []string{"Function", "Callback"}
+	enum := []string{"Enum"}
+	item := []string{"EnumItem"}
+	typ := []string{"Type"}
+	writeSecurity := column{_WRITE_SECURITY, colSecurity}
+	// Types have no history of their own.
+	entities := slices.DeleteFunc(slices.Clone(k.all), func(typ string) bool { return typ == "Type" })
+	return []selector{
+		{name: "is", value: valueKindName, types: k.all, list: k.all},
+		{name: "tag", value: valueTag, types: k.all, list: k.all},
+		{name: "primary", value: valueString, col: colPrimary, types: k.all, list: k.all},
+		{name: "secondary", value: valueString, col: colSecondary, types: k.secondary, list: k.secondary},
+		{name: "removed", value: valueBool, col: column{_FLAGS, colRemoved}, types: k.all, list: k.all},
+		{name: "added", value: valueUpdate, col: column{kind: colAdded}, types: entities, list: entities},
+		{name: "changedin", value: valueUpdate, col: column{kind: colChanged}, types: entities, list: entities},
+		{name: "removedin", value: valueUpdate, col: column{kind: colRemovedIn}, types: entities, list: entities},
+		{name: "superclasses", value: valueNumber, col: column{_SUPERCLASSES, colNumber}, types: class, list: class},
+		{name: "subclasses", value: valueNumber, col: column{_SUBCLASSES, colNumber}, types: class, list: class},
+		{name: "members", value: valueNumber, col: column{_MEMBERS, colNumber}, types: class, list: class},
+		{name: "ancestor", value: valueNumber, col: column{_ANCESTOR, colNumber}, types: class, list: class},
+		{name: "superclass", value: valueString, col: column{_SUPERCLASS, colString}, types: class, list: class},
+		{name: "subclass", value: valueString, col: column{_SUBCLASS, colString}, types: class, list: class},
+		{name: "memcat", value: valueString, col: column{_MEM_CAT, colString}, types: class, list: class},
+		{name: "threadsafety", value: valueString, col: column{_THREAD_SAFETY, colSafety}, types: k.members, list: k.members},
+		{name: "security", value: valueString, col: column{_SECURITY, colSecurity}, types: k.members, list: k.members, property: &writeSecurity},
+		{name: "cansave", value: valueBool, col: column{_CAN_SAVE, colBool}, types: k.members, list: property},
+		{name: "canload", value: valueBool, col: column{_CAN_LOAD, colBool}, types: k.members, list: property},
+		{name: "readsecurity", value: valueString, col: column{_READ_SECURITY, colSecurity}, types: property, list: property},
+		{name: "writesecurity", value: valueString, col: writeSecurity, types: property, list: property},
+		{name: "valuetypecat", value: valueString, col: column{_VALUE_TYPE_CAT, colCategory}, types: property, list: property},
+		{name: "valuetypename", value: valueString, col: column{_VALUE_TYPE_NAME, colString}, types: property, list: property},
+		{name: "category", value: valueString, col: column{_CATEGORY, colString}, types: property, list: property},
+		{name: "default", value: valueDefault, col: column{_DEFAULT, colString}, types: property, list: property},
+		{name: "returns", value: valueNumber, col: column{_RETURNS, colNumber}, types: fcs, list: fecs},
+		{name: "parameters", value: valueNumber, col: column{_PARAMETERS, colNumber}, types: fecs, list: fecs},
+		{name: "paramtypeopt", value: valueBool, col: column{_PARAM_TYPE_OPT, colBool}, types: fecs, list: fecs},
+		{name: "returntypeopt", value: valueBool, col: column{_RETURN_TYPE_OPT, colBool}, types: fecs, list: fecs},
+		{name: "paramtypecat", value: valueString, col: column{_PARAM_TYPE_CAT, colCategory}, types: fecs, list: fecs},
+		{name: "returntypecat", value: valueString, col: column{_RETURN_TYPE_CAT, colCategory}, types: fcs, list: fecs},
+		{name: "returntypename", value: valueString, col: column{_RETURN_TYPE_NAME, colString}, types: fcs, list: fecs},
+		{name: "paramtypename", value: valueString, col: column{_PARAM_TYPE_NAME, colString}, types: fecs, list: fecs},
+		{name: "paramname", value: valueString, col: column{_PARAM_NAME, colString}, types: fecs, list: fecs},
+		{name: "paramdefault", value: valueDefault, col: column{_PARAM_DEFAULT, colString}, types: fcs, list: fecs},
+		{name: "enumitems", value: valueNumber, col: column{_ENUM_ITEMS, colNumber}, types: enum, list: enum},
+		{name: "legacynames", value: valueNumber, col: column{_LEGACY_NAMES, colNumber}, types: item, list: item},
+		{name: "itemvalue", value: valueNumber, col: column{_ITEM_VALUE, colNumber}, types: item, list: item},
+		{name: "legacyname", value: valueString, col: column{_LEGACY_NAME, colString}, types: item, list: item},
+		{name: "typecat", value: valueString, col: column{_TYPE_CAT, colCategory}, types: typ, list: typ},
+	}
+}
+
+// Returns a query that fuzzily matches the names of entities against s. This
+// is used by the site when a query fails to parse.
+func (db *DB) Fuzzy(s string) *Query {
+	k := db.kinds()
+	return &Query{expr: nameExpr(k, matchFuzzy(s))}
+}
+
+// Parses a search query.
+func (db *DB) Parse(query string) (q *Query, err error) {
+	k := db.kinds()
+	p := parser{
+		s:         query,
+		kinds:     k,
+		selectors: selectors(k),
+		q:         &Query{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			q, err = nil, e
+		}
+	}()
+	p.space()
+	e, ok := p.expr()
+	if !ok {
+		return nil, p.errorf("expected selector, got %s", p.got())
+	}
+	p.space()
+	if p.i < len(p.s) {
+		c, _ := utf8.DecodeRuneInString(p.s[p.i:])
+		delim := `"`
+		if c == '"' {
+			delim = "'"
+		}
+		return nil, p.errorf("unexpected character %s%c%s", delim, c, delim)
+	}
+	p.q.expr = e
+	return p.q, nil
+}
+
+// An error that aborts parsing.
+type parseError struct {
+	line, column int
+	msg          string
+}
+
+func (err parseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", err.line, err.column, err.msg)
+}
+
+// Recursive descent parser for search queries. Each rule reports whether it
+// matched. A rule that does not match leaves the position unchanged.
+type parser struct {
+	s         string
+	i         int
+	kinds     kinds
+	selectors []selector
+	q         *Query
+}
+
+// Returns an error located at the current position.
+func (p *parser) errorf(format string, args ...any) parseError {
+	r := p.s[:p.i]
+	err := parseError{
+		line: strings.Count(r, "\n") + 1,
+		msg:  fmt.Sprintf(format, args...),
+	}
+	if j := strings.LastIndexByte(r, '\n'); j >= 0 {
+		err.column = p.i - j
+	} else {
+		err.column = len(r) + 1
+	}
+	return err
+}
+
+// Describes the character at the current position for error messages.
+func (p *parser) got() string {
+	if p.i >= len(p.s) {
+		return "end of query"
+	}
+	c, _ := utf8.DecodeRuneInString(p.s[p.i:])
+	return string(c)
+}
+
+// Matches s exactly.
+func (p *parser) lit(s string) bool {
+	if strings.HasPrefix(p.s[p.i:], s) {
+		p.i += len(s)
+		return true
+	}
+	return false
+}
+
+// Matches s, ignoring case.
+func (p *parser) litFold(s string) bool {
+	if len(p.s)-p.i >= len(s) && strings.EqualFold(p.s[p.i:p.i+len(s)], s) {
+		p.i += len(s)
+		return true
+	}
+	return false
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' ||
+		'0' <= c && c <= '9' ||
+		'a' <= c && c <= 'z' ||
+		'A' <= c && c <= 'Z'
+}
+
+// Matches w, ignoring case, followed by a word boundary.
+func (p *parser) word(w string) bool {
+	i := p.i
+	if !p.litFold(w) {
+		return false
+	}
+	if p.i < len(p.s) && isWordChar(p.s[p.i]) {
+		p.i = i
+		return false
+	}
+	return true
+}
+
+// Matches a sequence of word characters.
+func (p *parser) wordToken() (s string, ok bool) {
+	j := p.i
+	for j < len(p.s) && isWordChar(p.s[j]) {
+		j++
+	}
+	if j == p.i {
+		return "", false
+	}
+	s, p.i = p.s[p.i:j], j
+	return s, true
+}
+
+// Matches a sequence of digits.
+func (p *parser) digits() bool {
+	j := p.i
+	for j < len(p.s) && '0' <= p.s[j] && p.s[j] <= '9' {
+		j++
+	}
+	if j == p.i {
+		return false
+	}
+	p.i = j
+	return true
+}
+
+// Matches a sequence of whitespace characters, returning whether any were
+// matched.
+func (p *parser) whitespace() bool {
+	i := p.i
+	for p.i < len(p.s) {
+		c, n := utf8.DecodeRuneInString(p.s[p.i:])
+		if !unicode.IsSpace(c) {
+			break
+		}
+		p.i += n
+	}
+	return p.i > i
+}
+
+// Matches optional spacing, which may include block comments.
+func (p *parser) space() {
+	p.whitespace()
+	const commentOpen, commentClose = "#{", "}#"
+	if strings.HasPrefix(p.s[p.i:], commentOpen) {
+		j := strings.Index(p.s[p.i+len(commentOpen):], commentClose)
+		if j < 0 {
+			return
+		}
+		p.i += len(commentOpen) + j + len(commentClose)
+		p.space()
+	}
+}
+
+func (p *parser) orOp() bool {
+	i := p.i
+	p.space()
+	if !p.lit("||") && !p.lit(",") {
+		p.i = i
+		return false
+	}
+	p.space()
+	return true
+}
+
+func (p *parser) andOp() bool {
+	i := p.i
+	p.space()
+	if !p.lit("&&") {
+		p.i = i
+		if !p.whitespace() {
+			return false
+		}
+	}
+	p.space()
+	return true
+}
+
+func (p *parser) notOp() bool {
+	i := p.i
+	p.space()
+	if !p.lit("!") {
+		p.i = i
+		return false
+	}
+	p.space()
+	return true
+}
+
+// Collapses a binary expression. Returns nil for no operands.
+func collapse(operands []expr, and bool) expr {
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+	if and {
+		return exprAnd(operands)
+	}
+	return exprOr(operands)
+}
+
+// Parses operands separated by an OR operator.
+func (p *parser) expr() (expr, bool) {
+	e, ok := p.expr1()
+	if !ok {
+		return nil, false
+	}
+	var operands []expr
+	if e != nil {
+		operands = append(operands, e)
+	}
+	for {
+		i := p.i
+		if !p.orOp() {
+			break
+		}
+		e, ok := p.expr1()
+		if !ok {
+			p.i = i
+			break
+		}
+		if e != nil {
+			operands = append(operands, e)
+		}
+	}
+	return collapse(operands, false), true
+}
+
+// Parses operands separated by an AND operator.
+func (p *parser) expr1() (expr, bool) {
+	e, ok := p.expr2()
+	if !ok {
+		return nil, false
+	}
+	var operands []expr
+	if e != nil {
+		operands = append(operands, e)
+	}
+	for {
+		i := p.i
+		if !p.andOp() {
+			break
+		}
+		e, ok := p.expr2()
+		if !ok {
+			p.i = i
+			break
+		}
+		if e != nil {
+			operands = append(operands, e)
+		}
+	}
+	return collapse(operands, true), true
+}
+
+// Parses a selector, negation, or group.
+func (p *parser) expr2() (expr, bool) {
+	if e, ok := p.selector(); ok {
+		return e, true
+	}
+	i := p.i
+	if p.notOp() {
+		if e, ok := p.expr2(); ok {
+			if e == nil {
+				// Nothing to negate.
+				return nil, true
+			}
+			return exprNot{e}, true
+		}
+		p.i = i
+	}
+	if p.lit("(") {
+		p.space()
+		if e, ok := p.expr(); ok {
+			p.space()
+			if p.lit(")") {
+				return e, true
+			}
+		}
+		p.i = i
+	}
+	return nil, false
+}
+
+func (p *parser) selector() (expr, bool) {
+	if p.results() {
+		return nil, true
+	}
+	if e, ok := p.prefixes(); ok {
+		return e, true
+	}
+	if e, ok := p.compound(); ok {
+		return e, true
+	}
+	i := p.i
+	if m, arg, ok := p.stringExpr(); ok {
+		if isWordChar(p.s[i]) {
+			// A bare word also matches documentation.
+			return exprOr{
+				nameExpr(p.kinds, m),
+				exprDoc{types: p.kinds.all, token: strings.ToLower(arg)},
+			}, true
+		}
+		return nameExpr(p.kinds, m), true
+	}
+	return nil, false
+}
+
+// Parses result selectors of the form "/name:value".
+func (p *parser) results() bool {
+	i := p.i
+	if !p.lit("/") {
+		return false
+	}
+	j := p.i
+	if p.word("limit") && p.lit(":") {
+		if n, ok := p.number(); ok {
+			p.q.limit, p.q.hasLimit = n, true
+			return true
+		}
+	}
+	p.i = j
+	if p.word("sort") && p.lit(":") {
+		// Recognized, but has no effect, as on the site.
+		if _, ok := p.wordToken(); ok {
+			if !p.lit("<") {
+				p.lit(">")
+			}
+			return true
+		}
+	}
+	p.i = j
+	if p.word("go") && p.lit(":") {
+		// Only meaningful to the site.
+		if _, ok := p.wordToken(); ok {
+			return true
+		}
+	}
+	p.i = i
+	return false
+}
+
+// Parses field and list selectors of the form "name:value" and "name:".
+func (p *parser) prefixes() (expr, bool) {
+	i := p.i
+	if p.lit("*") {
+		if p.lit(":") {
+			p.q.list = append(p.q.list, list{kind: listFields})
+			return nil, true
+		}
+		p.i = i
+	}
+	name, ok := p.wordToken()
+	if !ok || !p.lit(":") {
+		p.i = i
+		return nil, false
+	}
+	name = strings.ToLower(name)
+	if name == "memecat" {
+		_, arg, ok := p.stringExpr()
+		msg := `˖⁺‧₊˚˖⁺‧₊˚˖⁺‧₊˚˖⁺‧₊˚ᓚ₍ ˆ•⩊•ˆ₎`
+		if ok && arg != "" {
+			msg += ` ⦟⟮ ` + arg + ` ⟯`
+		}
+		p.q.messages = append(p.q.messages, msg)
+		return nil, true
+	}
+	if name == "doc" {
+		// Not listed with other fields, since documentation has no value to
+		// list.
+		if w, ok := p.wordToken(); ok {
+			return exprDoc{types: p.kinds.all, token: strings.ToLower(w)}, true
+		}
+		return nil, true
+	}
+	j := slices.IndexFunc(p.selectors, func(s selector) bool { return s.name == name })
+	if j < 0 {
+		p.i = i
+		return nil, false
+	}
+	sel := p.selectors[j]
+
+	var m matcher
+	ok = false
+	switch sel.value {
+	case valueKindName:
+		start := p.i
+		if w, ok := p.wordToken(); ok {
+			return p.kindExpr(w, start), true
+		}
+	case valueTag:
+		if w, ok := p.wordToken(); ok {
+			return exprFlag{types: sel.types, flag: strings.ToLower(w)}, true
+		}
+	case valueString:
+		m, _, ok = p.stringExpr()
+	case valueNumber:
+		m, ok = p.numberExpr()
+	case valueBool:
+		m, ok = p.boolExpr()
+	case valueUpdate:
+		m, ok = p.updateExpr()
+	case valueDefault:
+		if m, ok = p.numberExpr(); !ok {
+			m, _, ok = p.stringExpr()
+		}
+	}
+	if !ok {
+		// No value; list the values of the field instead.
+		l := list{kind: listValues, col: sel.col, types: sel.list}
+		switch sel.value {
+		case valueKindName:
+			l.kind = listKinds
+		case valueTag:
+			l.kind = listTags
+		}
+		p.q.list = append(p.q.list, l)
+		return nil, true
+	}
+	e := exprOp{types: sel.types, col: sel.col, match: m}
+	if sel.property != nil {
+		return exprOr{e, exprOp{types: []string{"Property"}, col: *sel.property, match: m}}, true
+	}
+	return e, true
+}
+
+// Returns an expression that matches entity kinds according to w.
+func (p *parser) kindExpr(w string, start int) expr {
+	switch strings.ToLower(w) {
+	case "class":
+		return exprAny{"Class"}
+	case "property":
+		return exprAny{"Property"}
+	case "function":
+		return exprAny{"Function"}
+	case "event":
+		return exprAny{"Event"}
+	case "callback":
+		return exprAny{"Callback"}
+	case "enum":
+		return exprAny{"Enum"}
+	case "enumitem":
+		return exprAny{"EnumItem"}
+	case "type":
+		return exprAny{"Type"}
+	case "primary":
+		return exprAny(p.kinds.primary)
+	case "secondary":
+		return exprAny(p.kinds.secondary)
+	case "member":
+		return exprAny(p.kinds.members)
+	}
+	p.i = start
+	panic(p.errorf("unknown selector 'is:%s'", w))
+}
+
+// Parses a selector for dot-separated names.
+func (p *parser) compound() (expr, bool) {
+	i := p.i
+	if a, _, ok := p.stringExpr(); ok && p.lit(".") {
+		j := p.i
+		if b, _, ok := p.stringExpr(); ok {
+			return exprAnd{
+				exprOp{types: p.kinds.secondary, col: colPrimary, match: a},
+				exprOp{types: p.kinds.secondary, col: colSecondary, match: b},
+			}, true
+		}
+		p.i = j
+		return exprOp{types: p.kinds.primary, col: colPrimary, match: a}, true
+	}
+	p.i = i
+	if p.lit(".") {
+		if b, _, ok := p.stringExpr(); ok {
+			return exprOp{types: p.kinds.secondary, col: colSecondary, match: b}, true
+		}
+	}
+	p.i = i
+	return nil, false
+}
+
+// Returns an expression that matches m against primary or secondary names.
+func nameExpr(k kinds, m matcher) expr {
+	return exprOr{
+		exprOp{types: k.primary, col: colPrimary, match: m},
+		exprOp{types: k.secondary, col: colSecondary, match: m},
+	}
+}
+
+// Parses a string component. Also returns the argument of the component, if
+// any.
+func (p *parser) stringExpr() (m matcher, arg string, ok bool) {
+	if p.lit("*") {
+		return matchTrue(), "", true
+	}
+	if w, ok := p.wordToken(); ok {
+		return matchFuzzy(w), w, true
+	}
+	if s, ok := p.quoted('\''); ok {
+		return matchSub(s), s, true
+	}
+	if s, ok := p.quoted('"'); ok {
+		return matchEqual(s), s, true
+	}
+	if s, ok := p.quoted('~'); ok {
+		return matchFuzzy(s), s, true
+	}
+	return p.regexp()
+}
+
+// Parses a string delimited by delim, processing escape sequences.
+func (p *parser) quoted(delim byte) (s string, ok bool) {
+	i := p.i
+	if !p.lit(string(delim)) {
+		return "", false
+	}
+	var b strings.Builder
+	for p.i < len(p.s) && p.s[p.i] != delim {
+		if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+			p.i++
+			b.WriteString(p.escape())
+			continue
+		}
+		c, n := utf8.DecodeRuneInString(p.s[p.i:])
+		b.WriteRune(c)
+		p.i += n
+	}
+	if !p.lit(string(delim)) {
+		p.i = i
+		return "", false
+	}
+	return b.String(), true
+}
+
+// Parses the portion of an escape sequence following the backslash.
+func (p *parser) escape() string {
+	hex := func(n int) (rune, bool) {
+		if len(p.s)-(p.i+1) < n {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(p.s[p.i+1:p.i+1+n], 16, 32)
+		if err != nil {
+			return 0, false
+		}
+		p.i += 1 + n
+		return rune(v), true
+	}
+	switch c := p.s[p.i]; c {
+	case '\\', '"', '\'', '~', '/':
+		p.i++
+		return string(c)
+	case 't':
+		p.i++
+		return "\t"
+	case 'n':
+		p.i++
+		return "\n"
+	case 'r':
+		p.i++
+		return "\r"
+	case '\n':
+		p.i++
+		return ""
+	case 'x':
+		if v, ok := hex(2); ok {
+			return string(v)
+		}
+	case 'u':
+		if v, ok := hex(4); ok {
+			return string(v)
+		}
+	case 'U':
+		if v, ok := hex(8); ok {
+			if v > unicode.MaxRune {
+				return "�"
+			}
+			return string(v)
+		}
+	}
+	c, n := utf8.DecodeRuneInString(p.s[p.i:])
+	p.i += n
+	return string(c)
+}
+
+// Parses a regular expression of the form "/pattern/flags".
+func (p *parser) regexp() (m matcher, arg string, ok bool) {
+	i := p.i
+	if !p.lit("/") {
+		return nil, "", false
+	}
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] != '/' {
+		if strings.HasPrefix(p.s[p.i:], `\/`) {
+			p.i += 2
+			continue
+		}
+		p.i++
+	}
+	pattern := p.s[start:p.i]
+	if !p.lit("/") {
+		p.i = i
+		return nil, "", false
+	}
+	var flags strings.Builder
+	for p.i < len(p.s) && strings.IndexByte("imsuv", p.s[p.i]) >= 0 {
+		switch c := p.s[p.i]; c {
+		case 'i', 'm', 's':
+			// Other flags have no equivalent.
+			flags.WriteByte(c)
+		}
+		p.i++
+	}
+	expr := pattern
+	if flags.Len() > 0 {
+		expr = "(?" + flags.String() + ")" + pattern
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		panic(p.errorf("invalid regular expression: %s", err))
+	}
+	return matchRegexp(re), p.s[i:p.i], true
+}
+
+// Parses a bool component.
+func (p *parser) boolExpr() (matcher, bool) {
+	if p.lit("*") {
+		return matchTrue(), true
+	}
+	if p.word("0") || p.word("no") || p.word("false") {
+		return matchEqual(false), true
+	}
+	if p.word("1") || p.word("yes") || p.word("true") {
+		return matchEqual(true), true
+	}
+	return nil, false
+}
+
+// Parses a number component.
+func (p *parser) numberExpr() (matcher, bool) {
+	if p.lit("*") {
+		return matchTrue(), true
+	}
+	i := p.i
+	if lower, ok := p.number(); ok && p.lit("..") {
+		if upper, ok := p.number(); ok {
+			return matchRange(lower, upper), true
+		}
+	}
+	p.i = i
+	op := "="
+	for _, o := range []string{"<=", "<", ">=", ">"} {
+		if p.lit(o) {
+			op = o
+			break
+		}
+	}
+	if n, ok := p.number(); ok {
+		return matchCompare(op, n), true
+	}
+	p.i = i
+	return nil, false
+}
+
+// Parses a number.
+func (p *parser) number() (n float64, ok bool) {
+	i := p.i
+	neg := false
+	if p.lit("-") {
+		neg = true
+	} else {
+		p.lit("+")
+	}
+	if p.litFold("inf") {
+		n, ok = math.Inf(1), true
+	} else {
+		start := p.i
+		if p.digits() {
+			j := p.i
+			if !p.lit(".") || !p.digits() {
+				p.i = j
+			}
+			ok = true
+		} else if p.lit(".") && p.digits() {
+			ok = true
+		}
+		if ok {
+			n, _ = strconv.ParseFloat(p.s[start:p.i], 64)
+		}
+	}
+	if ok {
+		if neg {
+			n = -n
+		}
+		return n, true
+	}
+	p.i = i
+	if p.litFold("nan") {
+		return math.NaN(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Values against which matchers are compared.
+var probeValues = []any{
+	"", "foo", "Foo", "xfoox", "bar", "4", "None",
+	-1, 0, 3, 4, 5, 4.5, math.Inf(1),
+	true, false,
+	Update{GUID: "version-abc", Date: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)},
+	Update{GUID: "version-def", Date: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+	Update{GUID: "version-123", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// Reports whether two matchers produce the same score for each probe value.
+func sameMatcher(a, b matcher) bool {
+	for _, v := range probeValues {
+		x, y := a(v), b(v)
+		if x != y && !(math.IsNaN(x) && math.IsNaN(y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether two columns decode the same field in the same way. Fields
+// are compared by name, since their methods cannot be compared.
+func sameColumn(a, b column) bool {
+	return a.field.name == b.field.name && a.kind == b.kind
+}
+
+// Reports whether two list selectors are equal.
+func sameList(a, b list) bool {
+	return a.kind == b.kind && sameColumn(a.col, b.col) && slices.Equal(a.types, b.types)
+}
+
+// Reports whether two expressions are equal, comparing matchers by their
+// scores.
+func sameExpr(a, b expr) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case exprOp:
+		b, ok := b.(exprOp)
+		return ok && slices.Equal(a.types, b.types) && sameColumn(a.col, b.col) && sameMatcher(a.match, b.match)
+	case exprAnd:
+		b, ok := b.(exprAnd)
+		return ok && slices.EqualFunc(a, b, sameExpr)
+	case exprOr:
+		b, ok := b.(exprOr)
+		return ok && slices.EqualFunc(a, b, sameExpr)
+	case exprNot:
+		b, ok := b.(exprNot)
+		return ok && sameExpr(a.operand, b.operand)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Formats an expression for failure messages.
+func formatExpr(e expr) string {
+	switch e := e.(type) {
+	case nil:
+		return "nil"
+	case exprOp:
+		return fmt.Sprintf("op(%v, %s)", e.types, e.col.field.name)
+	case exprAnd:
+		return formatOperands("and", e)
+	case exprOr:
+		return formatOperands("or", e)
+	case exprNot:
+		return "not(" + formatExpr(e.operand) + ")"
+	}
+	return fmt.Sprintf("%#v", e)
+}
+
+// Formats the operands of a binary expression.
+func formatOperands(name string, operands []expr) string {
+	s := make([]string, len(operands))
+	for i, op := range operands {
+		s[i] = formatExpr(op)
+	}
+	return name + "(" + strings.Join(s, ", ") + ")"
+}
+
+// A query and its expected expression and list selectors, or its expected
+// error.
+type parseTest struct {
+	query string
+	expr  expr
+	list  []list
+	err   string
+}
+
+func TestParse(t *testing.T) {
+	db := &DB{EntityTypes: []string{"Class", "Property", "Function", "Event", "Callback", "Enum", "EnumItem", "Type"}}
+	k := db.kinds()
+	entities := []string{"Class", "Property", "Function", "Event", "Callback", "Enum", "EnumItem"}
+	class := []string{"Class"}
+	fuzzy := func(w string) expr {
+		return exprOr{nameExpr(k, matchFuzzy(w)), exprDoc{types: k.all, token: strings.ToLower(w)}}
+	}
+	removed := func(v bool) expr {
+		return exprOp{types: k.all, col: column{_FLAGS, colRemoved}, match: matchEqual(v)}
+	}
+	update := func(kind columnKind, m matcher) expr {
+		return exprOp{types: entities, col: column{kind: kind}, match: m}
+	}
+	day := dateDays(2024, 1, 2)
+	inf := math.Inf(1)
+
+	tests := []parseTest{
+		{query: `foo,bar !fizz,buzz`, expr: exprOr{
+			fuzzy("foo"),
+			exprAnd{fuzzy("bar"), exprNot{fuzzy("fizz")}},
+			fuzzy("buzz"),
+		}},
+		{query: `(foo,bar) (!fizz,buzz)`, expr: exprAnd{
+			exprOr{fuzzy("foo"), fuzzy("bar")},
+			exprOr{exprNot{fuzzy("fizz")}, fuzzy("buzz")},
+		}},
+		{query: `foo && bar || ! baz`, expr: exprOr{
+			exprAnd{fuzzy("foo"), fuzzy("bar")},
+			exprNot{fuzzy("baz")},
+		}},
+		{query: `~Foo~ 'foo' "foo"`, expr: exprAnd{
+			nameExpr(k, matchFuzzy("Foo")),
+			nameExpr(k, matchSub("foo")),
+			nameExpr(k, matchEqual("foo")),
+		}},
+		{query: `*`, expr: nameExpr(k, matchTrue())},
+		{query: `Foo.bar`, expr: exprAnd{
+			exprOp{types: k.secondary, col: colPrimary, match: matchFuzzy("Foo")},
+			exprOp{types: k.secondary, col: colSecondary, match: matchFuzzy("bar")},
+		}},
+		{query: `Foo.`, expr: exprOp{types: k.primary, col: colPrimary, match: matchFuzzy("Foo")}},
+		{query: `.bar`, expr: exprOp{types: k.secondary, col: colSecondary, match: matchFuzzy("bar")}},
+		{query: `#{comment}# Foo`, expr: fuzzy("Foo")},
+		{query: `!`, err: "1:1: expected selector, got !"},
+		{query: `(foo`, err: "1:1: expected selector, got ("},
+		{query: `foo)`, err: `1:4: unexpected character ")"`},
+
+		// List selectors.
+		{query: `is:`, list: []list{{kind: listKinds, types: k.all}}},
+		{query: `tag:`, list: []list{{kind: listTags, types: k.all}}},
+		{query: `*:`, list: []list{{kind: listFields}}},
+		{query: `security:`, list: []list{{kind: listValues, col: column{_SECURITY, colSecurity}, types: k.members}}},
+		{query: `threadsafety:`, list: []list{{kind: listValues, col: column{_THREAD_SAFETY, colSafety}, types: k.members}}},
+		{query: `typecat:`, list: []list{{kind: listValues, col: column{_TYPE_CAT, colCategory}, types: []string{"Type"}}}},
+		{query: `foo: bar`, err: `1:4: unexpected character ":"`},
+
+		{query: `is:class`, expr: exprAny{"Class"}},
+		{query: `is:member`, expr: exprAny(k.members)},
+		{query: `is:foo`, err: "1:4: unknown selector 'is:foo'"},
+
+		{query: `removed:`, list: []list{{kind: listValues, col: column{_FLAGS, colRemoved}, types: k.all}}},
+		{query: `removed:true`, expr: removed(true)},
+		{query: `removed:false`, expr: removed(false)},
+		{query: `removed:falsey`, err: `1:9: unexpected character "f"`},
+
+		{query: `tag:Deprecated`, expr: exprFlag{types: k.all, flag: "deprecated"}},
+		{query: `tag:foo`, expr: exprFlag{types: k.all, flag: "foo"}},
+
+		{query: `security:None`, expr: exprOr{
+			exprOp{types: k.members, col: column{_SECURITY, colSecurity}, match: matchFuzzy("None")},
+			exprOp{types: []string{"Property"}, col: column{_WRITE_SECURITY, colSecurity}, match: matchFuzzy("None")},
+		}},
+
+		{query: `doc:Foo`, expr: exprDoc{types: k.all, token: "foo"}},
+		{query: `doc:`},
+
+		{query: `added:`, list: []list{{kind: listValues, col: column{kind: colAdded}, types: entities}}},
+		{query: `added:*`, expr: update(colAdded, matchTrue())},
+		{query: `added:version-abc`, expr: update(colAdded, matchGUID("version-abc"))},
+		{query: `added:2024-01-02`, expr: update(colAdded, matchDays(day, day))},
+		{query: `changedin:<2024-01-02`, expr: update(colChanged, matchDays(-inf, day-1))},
+		{query: `changedin:>=2024-01-02`, expr: update(colChanged, matchDays(day, inf))},
+		{query: `removedin:2024-01-02..2024-01-31`, expr: update(colRemovedIn, matchDays(day, dateDays(2024, 1, 31)))},
+		{query: `removedin:>2024-01-02`, expr: update(colRemovedIn, matchDays(day+1, inf))},
+	}
+	for _, f := range []field{_SUPERCLASSES, _SUBCLASSES, _MEMBERS} {
+		col := column{f, colNumber}
+		name := strings.ToLower(f.name)
+		tests = append(tests, parseTest{query: name + ":", list: []list{{kind: listValues, col: col, types: class}}})
+		for _, op := range []string{"", "<", "<=", ">", ">="} {
+			m := matchCompare(cmp.Or(op, "="), 4)
+			tests = append(tests, parseTest{query: name + ":" + op + "4", expr: exprOp{types: class, col: col, match: m}})
+		}
+		tests = append(tests, parseTest{query: strings.ToUpper(name) + ":4", expr: exprOp{types: class, col: col, match: matchCompare("=", 4)}})
+	}
+
+	for _, test := range tests {
+		q, err := db.Parse(test.query)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", test.query, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		if !sameExpr(q.expr, test.expr) {
+			t.Errorf("%s: expected %s, got %s", test.query, formatExpr(test.expr), formatExpr(q.expr))
+		}
+		if !slices.EqualFunc(q.list, test.list, sameList) {
+			t.Errorf("%s: expected lists %v, got %v", test.query, test.list, q.list)
+		}
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	db := &DB{EntityTypes: []string{"Class", "Property", "Enum", "EnumItem", "Type"}}
+	q := db.Fuzzy("foo bar")
+	if want := nameExpr(db.kinds(), matchFuzzy("foo bar")); !sameExpr(q.expr, want) {
+		t.Errorf("expected %s, got %s", formatExpr(want), formatExpr(q.expr))
+	}
+}
@@ -0,0 +1,593 @@
+package search
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/robloxapi/rbxdump"
+)
+
+// A decoded search database.
+type DB struct {
+	// All strings in the database, by index.
+	Strings []string
+
+	// Names of entity types, in the order of their data tables.
+	EntityTypes []string
+	// Entity tags, ordered by flag bit.
+	Tags []string
+	// Security contexts.
+	Securities []string
+	// Thread safety levels.
+	ThreadSafeties []string
+	// Type categories.
+	TypeCategories []string
+
+	// Entities of each type, in the order they appear in the data tables.
+	Classes   []*Class
+	Members   []*Member
+	Enums     []*Enum
+	EnumItems []*EnumItem
+	Types     []*Type
+
+	// Updates recorded by the history index, in chronological order. Empty if
+	// the database has no history index.
+	Updates []Update
+
+	// Raw rows of each data table, in the same order as EntityTypes.
+	tables [][]byte
+	// Size of each row.
+	rowSize int
+	// Positions of fields within each row.
+	layout layout
+	// Maps documentation tokens to sorted global row indices. Nil if the
+	// database has no documentation index.
+	docs map[string][]int
+	// Maps global row indices to the history of the entity of the row. Nil if
+	// the database has no history index.
+	history map[int]entityHistory
+}
+
+// Fields common to all entities.
+type Entity struct {
+	Removed bool
+	Tags    []string
+}
+
+type Class struct {
+	Entity
+	Name           string
+	MemoryCategory string
+	MemberCount    int
+	Superclasses   []string // Ordered by ancestry.
+	Subclasses     []string
+}
+
+type Member struct {
+	Entity
+	MemberType   string
+	Class        string
+	Name         string
+	Security     string // Read security for properties.
+	ThreadSafety string
+
+	// Property
+
+	CanSave       bool
+	CanLoad       bool
+	WriteSecurity string
+	ValueType     rbxdump.Type
+	Category      string
+	Default       string
+
+	// Function, event, callback
+
+	Parameters []rbxdump.Parameter
+	ReturnType []rbxdump.Type
+}
+
+type Enum struct {
+	Entity
+	Name      string
+	ItemCount int
+}
+
+type EnumItem struct {
+	Entity
+	Enum        string
+	Name        string
+	Value       int
+	LegacyNames []string
+}
+
+type Type struct {
+	Entity
+	Name     string
+	Category string
+}
+
+// Wrapper for decoding various types.
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+// Returns the next n bytes, or nil if there are not enough bytes.
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b)-r.off < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) u8() int {
+	if b := r.next(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *reader) u16() int {
+	if b := r.next(2); b != nil {
+		return int(binary.LittleEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *reader) u32() int {
+	if b := r.next(4); b != nil {
+		return int(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// Decodes an unsigned integer of n bytes.
+func (r *reader) uN(n int) int {
+	v := 0
+	for i, c := range r.next(n) {
+		v |= int(c) << (8 * i)
+	}
+	return v
+}
+
+// Decodes a variable-length unsigned integer.
+func (r *reader) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b[r.off:])
+	if n <= 0 || v > math.MaxUint32 {
+		r.err = errors.New("malformed varint")
+		return 0
+	}
+	r.off += n
+	return int(v)
+}
+
+// Decodes a list of n string indices, each encoded with width bytes.
+func (r *reader) enum(strings []string, n, width int) []string {
+	list := make([]string, n)
+	for i := range list {
+		s := r.uN(width)
+		if r.err != nil {
+			return nil
+		}
+		if s >= len(strings) {
+			r.err = fmt.Errorf("string index %d out of range", s)
+			return nil
+		}
+		list[i] = strings[s]
+	}
+	return list
+}
+
+// Decodes the field descriptor of the table for the given entity type. Returns
+// an error if a field known to the reader is missing or encoded differently
+// than expected by l.
+func (r *reader) checkFields(strings []string, typ string, l layout) error {
+	n := r.u8()
+	desc := make(map[string]field, n)
+	for range n {
+		name := r.uN(l.strWidth)
+		code := r.u8()
+		offset := r.u8()
+		if r.err != nil {
+			return r.err
+		}
+		if name >= len(strings) {
+			return fmt.Errorf("string index %d out of range", name)
+		}
+		desc[strings[name]] = field{
+			name:   strings[name],
+			method: method{code: uint8(code)},
+			offset: offset,
+		}
+	}
+	for _, f := range fieldsOf(typ) {
+		f = l.resolve(f)
+		d, ok := desc[f.name]
+		if !ok {
+			return fmt.Errorf("missing field %s", f.name)
+		}
+		if d.method.code != f.method.code || d.offset != f.offset {
+			return fmt.Errorf("unsupported encoding of field %s", f.name)
+		}
+	}
+	return nil
+}
+
+// Decodes n rows of the given size in the compact format, returning the rows
+// in the fixed format.
+func (r *reader) compactRows(n, size int) []byte {
+	rows := make([]byte, 0, min(n, len(r.b))*size)
+	for range n {
+		bitmap := r.next((size + 7) / 8)
+		if bitmap == nil {
+			return nil
+		}
+		for j := range size {
+			if bitmap[j/8]&(1<<(j%8)) == 0 {
+				rows = append(rows, 0xFF)
+			} else if b := r.next(1); b != nil {
+				rows = append(rows, b[0])
+			} else {
+				return nil
+			}
+		}
+	}
+	return rows
+}
+
+// Decodes the documentation index into db.
+func (r *reader) docs(db *DB, width int) error {
+	rows := 0
+	for _, table := range db.tables {
+		rows += len(table) / db.rowSize
+	}
+	n := r.u32()
+	db.docs = make(map[string][]int, min(n, len(r.b)))
+	for range n {
+		s := r.uN(width)
+		count := r.uvarint()
+		if r.err != nil {
+			return r.err
+		}
+		if s >= len(db.Strings) {
+			return fmt.Errorf("string index %d out of range", s)
+		}
+		postings := make([]int, 0, min(count, rows))
+		row := 0
+		for range count {
+			row += r.uvarint()
+			if r.err != nil {
+				return r.err
+			}
+			if row >= rows {
+				return fmt.Errorf("row index %d out of range", row)
+			}
+			postings = append(postings, row)
+		}
+		db.docs[db.Strings[s]] = postings
+	}
+	return r.err
+}
+
+// Decodes rows of a data table.
+type rowReader struct {
+	db  *DB
+	row []byte
+	err error
+}
+
+// Returns the value of f within the current row.
+func (r *rowReader) int(f field) (v int, ok bool) {
+	if f.method.decode == nil {
+		return 0, false
+	}
+	f = r.db.layout.resolve(f)
+	return f.method.decode(r.row, f.offset)
+}
+
+// Returns the string referred to by f within the current row.
+func (r *rowReader) string(f field) string {
+	v, ok := r.int(f)
+	if !ok {
+		return ""
+	}
+	if v >= len(r.db.Strings) {
+		if r.err == nil {
+			r.err = fmt.Errorf("string index %d out of range", v)
+		}
+		return ""
+	}
+	return r.db.Strings[v]
+}
+
+// Returns the item of list referred to by f within the current row.
+func (r *rowReader) enum(f field, list []string) string {
+	v, ok := r.int(f)
+	if !ok || v >= len(list) {
+		return ""
+	}
+	return list[v]
+}
+
+// Returns the boolean value of f within the current row.
+func (r *rowReader) bool(f field) bool {
+	v, ok := r.int(f)
+	return ok && v != 0
+}
+
+// Returns whether the current row is the main row of an entity, rather than a
+// secondary row.
+func (r *rowReader) main() bool {
+	_, ok := r.int(_FLAGS)
+	return ok
+}
+
+// Returns the entity flags of the current row.
+func (r *rowReader) entity() (e Entity) {
+	e.Removed, _ = r.db.layout.bit(r.row, 0)
+	for i, tag := range r.db.Tags {
+		if set, _ := r.db.layout.bit(r.row, i+1); set {
+			e.Tags = append(e.Tags, tag)
+		}
+	}
+	return e
+}
+
+// Returns the type with category and name encoded by the given fields.
+func (r *rowReader) typ(cat, name, opt field) rbxdump.Type {
+	return rbxdump.Type{
+		Category: r.enum(cat, r.db.TypeCategories),
+		Name:     r.string(name),
+		Optional: r.bool(opt),
+	}
+}
+
+// Decodes a search database from r.
+func Read(r io.Reader) (*DB, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	rd := reader{b: b}
+	db := &DB{}
+
+	if m := rd.next(len(magic)); string(m) != magic {
+		return nil, errors.New("not a search database")
+	}
+	if v := rd.u8(); v != version {
+		return nil, fmt.Errorf("unsupported format version %d", v)
+	}
+	db.rowSize = rd.u8()
+	strWidth := rd.u8()
+	if strWidth < 2 || strWidth > 4 {
+		return nil, fmt.Errorf("unsupported string index width %d", strWidth)
+	}
+	flagWidth := rd.u8()
+	if flagWidth < 4 {
+		return nil, fmt.Errorf("unsupported flags width %d", flagWidth)
+	}
+	db.layout = layoutOf(strWidth, flagWidth)
+	sections := rd.u8()
+	if sections&^(sectionDocs|sectionHistory) != 0 {
+		return nil, fmt.Errorf("unsupported sections %#x", sections)
+	}
+	format := Format(rd.u8())
+	if format != FormatFixed && format != FormatCompact {
+		return nil, fmt.Errorf("unsupported table format %d", format)
+	}
+	if db.rowSize < db.layout.rowSize() {
+		return nil, fmt.Errorf("unsupported row size %d", db.rowSize)
+	}
+	lenStrings := rd.u32()
+	lenBlob := rd.u32()
+	lenTypes := rd.u8()
+	lenTags := rd.u16()
+	lenSecs := rd.u8()
+	lenSafes := rd.u8()
+	lenCats := rd.u8()
+	tableRows := make([]int, lenTypes)
+	for i := range tableRows {
+		tableRows[i] = rd.u32()
+	}
+	if rd.err != nil {
+		return nil, fmt.Errorf("decode header: %w", rd.err)
+	}
+
+	sizes := make([]int, 0, min(lenStrings, len(b)))
+	for range lenStrings {
+		sizes = append(sizes, rd.uvarint())
+		if rd.err != nil {
+			return nil, fmt.Errorf("decode string sizes: %w", rd.err)
+		}
+	}
+	blob := rd.next(lenBlob)
+	if rd.err != nil {
+		return nil, fmt.Errorf("decode strings: %w", rd.err)
+	}
+	db.Strings = make([]string, lenStrings)
+	for i, o := 0, 0; i < lenStrings; i++ {
+		z := sizes[i]
+		if o+z > len(blob) {
+			return nil, errors.New("decode strings: string exceeds blob")
+		}
+		db.Strings[i] = string(blob[o : o+z])
+		o += z
+	}
+
+	db.EntityTypes = rd.enum(db.Strings, lenTypes, strWidth)
+	db.Tags = rd.enum(db.Strings, lenTags, strWidth)
+	db.Securities = rd.enum(db.Strings, lenSecs, strWidth)
+	db.ThreadSafeties = rd.enum(db.Strings, lenSafes, strWidth)
+	db.TypeCategories = rd.enum(db.Strings, lenCats, strWidth)
+	if rd.err != nil {
+		return nil, fmt.Errorf("decode enumerations: %w", rd.err)
+	}
+
+	for _, typ := range db.EntityTypes {
+		if err := rd.checkFields(db.Strings, typ, db.layout); err != nil {
+			return nil, fmt.Errorf("decode %s descriptor: %w", typ, err)
+		}
+	}
+
+	for i, typ := range db.EntityTypes {
+		var rows []byte
+		if format == FormatCompact {
+			rows = rd.compactRows(tableRows[i], db.rowSize)
+		} else {
+			rows = rd.next(tableRows[i] * db.rowSize)
+		}
+		if rd.err != nil {
+			return nil, fmt.Errorf("decode %s table: %w", typ, rd.err)
+		}
+		db.tables = append(db.tables, rows)
+		rr := rowReader{db: db}
+		for j := 0; j < tableRows[i]; j++ {
+			rr.row = rows[j*db.rowSize : (j+1)*db.rowSize]
+			switch typ {
+			case "Class":
+				db.readClass(&rr)
+			case "Enum":
+				db.readEnum(&rr)
+			case "EnumItem":
+				db.readEnumItem(&rr)
+			case "Type":
+				db.readType(&rr)
+			default:
+				db.readMember(&rr, typ)
+			}
+			if rr.err != nil {
+				return nil, fmt.Errorf("decode %s table row %d: %w", typ, j, rr.err)
+			}
+		}
+	}
+	if sections&sectionDocs != 0 {
+		if err := rd.docs(db, strWidth); err != nil {
+			return nil, fmt.Errorf("decode documentation index: %w", err)
+		}
+	}
+	if sections&sectionHistory != 0 {
+		if err := rd.history(db, strWidth); err != nil {
+			return nil, fmt.Errorf("decode history index: %w", err)
+		}
+	}
+	if rd.off != len(b) {
+		return nil, fmt.Errorf("decode: %d unexpected trailing bytes", len(b)-rd.off)
+	}
+
+	return db, nil
+}
+
+func (db *DB) readClass(r *rowReader) {
+	if r.main() {
+		v, _ := r.int(_MEMBERS)
+		db.Classes = append(db.Classes, &Class{
+			Entity:         r.entity(),
+			Name:           r.string(_CLASS_NAME),
+			MemoryCategory: r.string(_MEM_CAT),
+			MemberCount:    v,
+		})
+		return
+	}
+	if len(db.Classes) == 0 {
+		r.err = errors.New("secondary row without class")
+		return
+	}
+	class := db.Classes[len(db.Classes)-1]
+	if _, ok := r.int(_SUPERCLASS); ok {
+		class.Superclasses = append(class.Superclasses, r.string(_SUPERCLASS))
+	}
+	if _, ok := r.int(_SUBCLASS); ok {
+		class.Subclasses = append(class.Subclasses, r.string(_SUBCLASS))
+	}
+}
+
+func (db *DB) readMember(r *rowReader, memberType string) {
+	if r.main() {
+		member := &Member{
+			Entity:       r.entity(),
+			MemberType:   memberType,
+			Class:        r.string(_CLASS_NAME),
+			Name:         r.string(_MEMBER_NAME),
+			Security:     r.enum(_SECURITY, db.Securities),
+			ThreadSafety: r.enum(_THREAD_SAFETY, db.ThreadSafeties),
+		}
+		if memberType == "Property" {
+			member.CanSave = r.bool(_CAN_SAVE)
+			member.CanLoad = r.bool(_CAN_LOAD)
+			member.WriteSecurity = r.enum(_WRITE_SECURITY, db.Securities)
+			member.ValueType = r.typ(_VALUE_TYPE_CAT, _VALUE_TYPE_NAME, field{})
+			member.Category = r.string(_CATEGORY)
+			member.Default = r.string(_DEFAULT)
+		}
+		db.Members = append(db.Members, member)
+		return
+	}
+	if len(db.Members) == 0 {
+		r.err = errors.New("secondary row without member")
+		return
+	}
+	member := db.Members[len(db.Members)-1]
+	if _, ok := r.int(_RETURN_TYPE_NAME); ok {
+		member.ReturnType = append(member.ReturnType, r.typ(_RETURN_TYPE_CAT, _RETURN_TYPE_NAME, _RETURN_TYPE_OPT))
+	}
+	if _, ok := r.int(_PARAM_NAME); ok {
+		param := rbxdump.Parameter{
+			Type: r.typ(_PARAM_TYPE_CAT, _PARAM_TYPE_NAME, _PARAM_TYPE_OPT),
+			Name: r.string(_PARAM_NAME),
+		}
+		if _, ok := r.int(_PARAM_DEFAULT); ok {
+			param.Optional = true
+			param.Default = r.string(_PARAM_DEFAULT)
+		}
+		member.Parameters = append(member.Parameters, param)
+	}
+}
+
+func (db *DB) readEnum(r *rowReader) {
+	v, _ := r.int(_ENUM_ITEMS)
+	db.Enums = append(db.Enums, &Enum{
+		Entity:    r.entity(),
+		Name:      r.string(_ENUM_NAME),
+		ItemCount: v,
+	})
+}
+
+func (db *DB) readEnumItem(r *rowReader) {
+	if r.main() {
+		v, _ := r.int(_ITEM_VALUE)
+		db.EnumItems = append(db.EnumItems, &EnumItem{
+			Entity: r.entity(),
+			Enum:   r.string(_ENUM_NAME),
+			Name:   r.string(_ITEM_NAME),
+			Value:  v,
+		})
+		return
+	}
+	if len(db.EnumItems) == 0 {
+		r.err = errors.New("secondary row without enum item")
+		return
+	}
+	item := db.EnumItems[len(db.EnumItems)-1]
+	item.LegacyNames = append(item.LegacyNames, r.string(_LEGACY_NAME))
+}
+
+func (db *DB) readType(r *rowReader) {
+	db.Types = append(db.Types, &Type{
+		Entity:   r.entity(),
+		Name:     r.string(_TYPE_NAME),
+		Category: r.enum(_TYPE_CAT, db.TypeCategories),
+	})
+}
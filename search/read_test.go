@@ -0,0 +1,241 @@
+package search
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/robloxapi/rbxdump"
+	"github.com/robloxapi/rbxdump/diff"
+	"github.com/robloxapi/rbxver"
+	"github.com/robloxapi/roar/archive"
+	"github.com/robloxapi/roar/history"
+	"github.com/robloxapi/roar/index"
+)
+
+// Returns the dumps of two successive builds. The second build removes a
+// function and an enum item, and adds a class.
+func testDumps() (prev, next *rbxdump.Root) {
+	str := rbxdump.Type{Category: "DataType", Name: "string"}
+	instance := &rbxdump.Class{
+		Name:           "Instance",
+		MemoryCategory: "Instances",
+		Tags:           rbxdump.Tags{"NotCreatable"},
+		Members: map[string]rbxdump.Member{
+			"Name": &rbxdump.Property{
+				Name:          "Name",
+				ValueType:     str,
+				Category:      "Data",
+				ReadSecurity:  "None",
+				WriteSecurity: "PluginSecurity",
+				CanLoad:       true,
+				CanSave:       true,
+				ThreadSafety:  "ReadSafe",
+				Default:       "Instance",
+			},
+			"FindFirstChild": &rbxdump.Function{
+				Name: "FindFirstChild",
+				Parameters: []rbxdump.Parameter{
+					{Type: str, Name: "name"},
+					{Type: rbxdump.Type{Category: "DataType", Name: "bool"}, Name: "recursive", Optional: true, Default: "false"},
+				},
+				ReturnType:   []rbxdump.Type{{Category: "Class", Name: "Instance", Optional: true}},
+				Security:     "None",
+				ThreadSafety: "Safe",
+			},
+			"Remove": &rbxdump.Function{
+				Name:         "Remove",
+				ReturnType:   []rbxdump.Type{{Category: "DataType", Name: "null"}},
+				Security:     "None",
+				ThreadSafety: "Unsafe",
+				Tags:         rbxdump.Tags{"Deprecated"},
+			},
+		},
+	}
+	material := &rbxdump.Enum{
+		Name: "Material",
+		Items: map[string]*rbxdump.EnumItem{
+			"Plastic":  {Name: "Plastic", Value: 256, Index: 0, LegacyNames: []string{"Plastik", "Smooth"}},
+			"Negative": {Name: "Negative", Value: -1, Index: 1},
+			"Min":      {Name: "Min", Value: -2147483647, Index: 2},
+			"Old":      {Name: "Old", Value: 5, Index: 3, Tags: rbxdump.Tags{"Deprecated"}},
+		},
+	}
+	prev = &rbxdump.Root{
+		Classes: map[string]*rbxdump.Class{"Instance": instance},
+		Enums:   map[string]*rbxdump.Enum{"Material": material},
+	}
+
+	next = prev.Copy()
+	delete(next.Classes["Instance"].Members, "Remove")
+	delete(next.Enums["Material"].Items, "Old")
+	next.Classes["Part"] = &rbxdump.Class{
+		Name:           "Part",
+		Superclass:     "Instance",
+		MemoryCategory: "BaseParts",
+		Members:        map[string]rbxdump.Member{},
+	}
+	return prev, next
+}
+
+// Returns an encoded database of the test dumps.
+func encodeTestDB(t *testing.T, format Format) []byte {
+	t.Helper()
+	prev, next := testDumps()
+	builds := []archive.Build{
+		{GUID: "version-0001", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Version: rbxver.Version{Version: 600, Commit: 1}},
+		{GUID: "version-0002", Date: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Version: rbxver.Version{Version: 601, Commit: 2}},
+	}
+
+	hist := history.NewRoot()
+	differ := diff.Diff{Prev: &rbxdump.Root{}, SeparateFields: true}
+	for i, dump := range []*rbxdump.Root{prev, next} {
+		differ.Next = dump
+		actions := differ.Diff()
+		history.SortActions(actions)
+		hist.AppendUpdate(builds[i], actions, differ.Prev)
+		differ.Prev = differ.Next.Copy()
+	}
+
+	// Union of every entity, as generated for the site.
+	patcher := diff.Patch{Root: &rbxdump.Root{}}
+	for _, update := range hist.Update {
+		for _, change := range update.Changes {
+			if change.Action.Type != diff.Remove {
+				patcher.Patch([]diff.Action{change.Action})
+			}
+		}
+	}
+	union := patcher.Root
+
+	idx := &index.Root{}
+	if err := idx.Build(hist, union); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, idx, union, hist, nil, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Returns the names of the entities matching query.
+func searchNames(t *testing.T, db *DB, query string) []string {
+	t.Helper()
+	q, err := db.Parse(query)
+	if err != nil {
+		t.Fatalf("parse %q: %s", query, err)
+	}
+	var names []string
+	for _, r := range db.Search(q) {
+		names = append(names, r.String())
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatFixed, FormatCompact} {
+		db, err := Read(bytes.NewReader(encodeTestDB(t, format)))
+		if err != nil {
+			t.Fatalf("format %d: %s", format, err)
+		}
+		testRoundTrip(t, db)
+	}
+}
+
+func testRoundTrip(t *testing.T, db *DB) {
+	t.Helper()
+	classes := map[string]*Class{}
+	for _, class := range db.Classes {
+		classes[class.Name] = class
+	}
+	if len(classes) != 2 {
+		t.Fatalf("expected 2 classes, got %d", len(db.Classes))
+	}
+	instance := classes["Instance"]
+	if instance == nil || instance.Removed || !slices.Equal(instance.Tags, []string{"NotCreatable"}) {
+		t.Errorf("unexpected Instance class: %+v", instance)
+	} else if instance.MemoryCategory != "Instances" || !slices.Equal(instance.Subclasses, []string{"Part"}) {
+		t.Errorf("unexpected Instance class: %+v", instance)
+	}
+	if part := classes["Part"]; part == nil || !slices.Equal(part.Superclasses, []string{"Instance"}) {
+		t.Errorf("unexpected Part class: %+v", part)
+	}
+
+	members := map[string]*Member{}
+	for _, member := range db.Members {
+		members[member.Class+"."+member.Name] = member
+	}
+	if m := members["Instance.Name"]; m == nil {
+		t.Error("missing Instance.Name")
+	} else {
+		// A security of "None" is encoded as an empty string.
+		expected := Member{
+			MemberType:    "Property",
+			Class:         "Instance",
+			Name:          "Name",
+			ThreadSafety:  "ReadSafe",
+			CanSave:       true,
+			CanLoad:       true,
+			WriteSecurity: "PluginSecurity",
+			ValueType:     rbxdump.Type{Category: "DataType", Name: "string"},
+			Category:      "Data",
+			Default:       "Instance",
+		}
+		if !reflect.DeepEqual(*m, expected) {
+			t.Errorf("Instance.Name: expected %+v, got %+v", expected, *m)
+		}
+	}
+	if m := members["Instance.FindFirstChild"]; m == nil {
+		t.Error("missing Instance.FindFirstChild")
+	} else {
+		params := []rbxdump.Parameter{
+			{Type: rbxdump.Type{Category: "DataType", Name: "string"}, Name: "name"},
+			{Type: rbxdump.Type{Category: "DataType", Name: "bool"}, Name: "recursive", Optional: true, Default: "false"},
+		}
+		if !slices.Equal(m.Parameters, params) {
+			t.Errorf("Instance.FindFirstChild: expected parameters %+v, got %+v", params, m.Parameters)
+		}
+		returns := []rbxdump.Type{{Category: "Class", Name: "Instance", Optional: true}}
+		if !slices.Equal(m.ReturnType, returns) {
+			t.Errorf("Instance.FindFirstChild: expected returns %+v, got %+v", returns, m.ReturnType)
+		}
+	}
+	if m := members["Instance.Remove"]; m == nil {
+		t.Error("missing Instance.Remove")
+	} else if !m.Removed || !slices.Equal(m.Tags, []string{"Deprecated"}) {
+		t.Errorf("Instance.Remove: expected removed and deprecated, got %+v", m.Entity)
+	}
+
+	items := map[string]*EnumItem{}
+	for _, item := range db.EnumItems {
+		items[item.Name] = item
+	}
+	for name, value := range map[string]int{"Plastic": 256, "Negative": -1, "Min": -2147483647, "Old": 5} {
+		if item := items[name]; item == nil || item.Value != value {
+			t.Errorf("Material.%s: expected value %d, got %+v", name, value, item)
+		}
+	}
+	if item := items["Plastic"]; item != nil && !slices.Equal(item.LegacyNames, []string{"Plastik", "Smooth"}) {
+		t.Errorf("Material.Plastic: unexpected legacy names %v", item.LegacyNames)
+	}
+	if item := items["Old"]; item != nil && (!item.Removed || !slices.Equal(item.Tags, []string{"Deprecated"})) {
+		t.Errorf("Material.Old: expected removed and deprecated, got %+v", item.Entity)
+	}
+
+	if len(db.Updates) != 2 || db.Updates[0].GUID != "version-0001" || db.Updates[1].Version != "0.601.0.2" {
+		t.Errorf("unexpected updates %+v", db.Updates)
+	}
+	if names := searchNames(t, db, "added:version-0002"); !slices.Equal(names, []string{"Class Part"}) {
+		t.Errorf("added in version-0002: got %v", names)
+	}
+	if names := searchNames(t, db, "removedin:version-0002"); !slices.Equal(names, []string{"EnumItem Material.Old", "Function Instance.Remove"}) {
+		t.Errorf("removed in version-0002: got %v", names)
+	}
+	if names := searchNames(t, db, "itemvalue:<0"); !slices.Equal(names, []string{"EnumItem Material.Min", "EnumItem Material.Negative"}) {
+		t.Errorf("negative item values: got %v", names)
+	}
+}
@@ -0,0 +1,489 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A single search result.
+type Result struct {
+	// Score of the result. Higher is a better match.
+	Score float64
+
+	// Entity type, primary name, and secondary name of a matching entity.
+	// Empty if the result is a value.
+	Type      string
+	Primary   string
+	Secondary string
+
+	// Value of a result produced by a list selector, or a message. Nil if the
+	// result is an entity.
+	Value any
+}
+
+// Returns a readable representation of the result.
+func (r Result) String() string {
+	if r.Value != nil {
+		return fmt.Sprint(r.Value)
+	}
+	if r.Secondary != "" {
+		return r.Type + " " + r.Primary + "." + r.Secondary
+	}
+	return r.Type + " " + r.Primary
+}
+
+// A row within a data table.
+type row struct {
+	typ   string
+	data  []byte
+	index int // Index of the row across all tables.
+}
+
+// Returns the value of the field described by c. Returns false if the field is
+// unset.
+func (db *DB) value(r row, c column) (v any, ok bool) {
+	switch c.kind {
+	case colAdded, colChanged, colRemovedIn:
+		return db.update(r, c)
+	}
+	if c.field.method.decode == nil {
+		return nil, false
+	}
+	f := db.layout.resolve(c.field)
+	n, ok := f.method.decode(r.data, f.offset)
+	if !ok {
+		return nil, false
+	}
+	lookup := func(list []string) (any, bool) {
+		if n >= len(list) {
+			return nil, false
+		}
+		return list[n], true
+	}
+	switch c.kind {
+	case colString:
+		return lookup(db.Strings)
+	case colNumber:
+		return n, true
+	case colBool:
+		return n != 0, true
+	case colRemoved:
+		return db.layout.bit(r.data, 0)
+	case colSecurity:
+		return lookup(db.Securities)
+	case colSafety:
+		return lookup(db.ThreadSafeties)
+	case colCategory:
+		return lookup(db.TypeCategories)
+	}
+	return nil, false
+}
+
+// Returns the string value of the field described by c, or an empty string if
+// unset.
+func (db *DB) string(r row, c column) string {
+	v, _ := db.value(r, c)
+	s, _ := v.(string)
+	return s
+}
+
+// Compares the field of a row to a value. A match is indicated by a positive
+// score. A non-match is indicated by a negative score. Positive scores can have
+// an arbitrary magnitude, which is relative to the result of a fuzzy match.
+type matcher func(v any) float64
+
+// Converts v to a number, following the conversion rules of JavaScript.
+func toNumber(v any) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		switch s {
+		case "Infinity", "+Infinity":
+			return math.Inf(1)
+		case "-Infinity":
+			return math.Inf(-1)
+		}
+		// Exclude forms accepted only by ParseFloat, such as "inf" and "1_0".
+		if n, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "nN_") {
+			return n
+		}
+	}
+	return math.NaN()
+}
+
+// Converts v to a string, following the conversion rules of JavaScript.
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Matches anything.
+func matchTrue() matcher {
+	return func(v any) float64 { return 1 }
+}
+
+// Returns the score of a fuzzy match of s against the field, or a non-match
+// unless the full pattern is matched.
+func matchFuzzy(s string) matcher {
+	return func(v any) float64 {
+		if matched, score := fuzzyMatch(s, toString(v)); matched {
+			return float64(score)
+		}
+		return -1
+	}
+}
+
+// Matches if the field contains s as a substring.
+func matchSub(s string) matcher {
+	return func(v any) float64 {
+		if strings.Contains(toString(v), s) {
+			return 1
+		}
+		return -1
+	}
+}
+
+// Matches if the field matches the regular expression.
+func matchRegexp(re *regexp.Regexp) matcher {
+	return func(v any) float64 {
+		if re.MatchString(toString(v)) {
+			return 1
+		}
+		return -1
+	}
+}
+
+// Matches if the field is strictly equal to value.
+func matchEqual(value any) matcher {
+	return func(v any) float64 {
+		if v == value {
+			return 1
+		}
+		return -1
+	}
+}
+
+// Matches if the field is within the bounds of lower and upper. Returns the
+// field as the score.
+func matchRange(lower, upper float64) matcher {
+	return func(v any) float64 {
+		if n := toNumber(v); lower <= n && n <= upper {
+			return n
+		}
+		return -1
+	}
+}
+
+// Matches if the field compares to value according to op. Returns the field as
+// the score.
+func matchCompare(op string, value float64) matcher {
+	return func(v any) float64 {
+		n := toNumber(v)
+		var ok bool
+		switch op {
+		case "=":
+			ok = n == value
+		case "<":
+			ok = n < value
+		case "<=":
+			ok = n <= value
+		case ">":
+			ok = n > value
+		case ">=":
+			ok = n >= value
+		}
+		if ok {
+			return math.Max(1, n)
+		}
+		return -1
+	}
+}
+
+// A node of a logical expression that scores a row. A score of 0 indicates an
+// undefined field, and is also considered a non-match. Negative scores are
+// normalized to -1.
+type expr interface {
+	score(db *DB, r row) float64
+	// Appends the entity types relevant to the expression.
+	appendTypes(types []string) []string
+}
+
+// Matches a field of rows of the given types.
+type exprOp struct {
+	types []string
+	col   column
+	match matcher
+}
+
+func (e exprOp) score(db *DB, r row) float64 {
+	if !slices.Contains(e.types, r.typ) {
+		return 0
+	}
+	v, ok := db.value(r, e.col)
+	if !ok {
+		return 0
+	}
+	return math.Max(-1, e.match(v))
+}
+
+func (e exprOp) appendTypes(types []string) []string {
+	return append(types, e.types...)
+}
+
+// Matches rows of the given types that have a tag.
+type exprFlag struct {
+	types []string
+	flag  string
+}
+
+func (e exprFlag) score(db *DB, r row) float64 {
+	if !slices.Contains(e.types, r.typ) {
+		return 0
+	}
+	if _, ok := db.layout.bit(r.data, 0); !ok {
+		return 0
+	}
+	// First bit is reserved for removed flag.
+	n := slices.IndexFunc(db.Tags, func(tag string) bool {
+		return strings.ToLower(tag) == e.flag
+	}) + 1
+	if n == 0 {
+		return 0
+	}
+	if set, _ := db.layout.bit(r.data, n); set {
+		return 1
+	}
+	return -1
+}
+
+func (e exprFlag) appendTypes(types []string) []string {
+	return append(types, e.types...)
+}
+
+// Matches rows of the given types.
+type exprAny []string
+
+func (e exprAny) score(db *DB, r row) float64 {
+	if slices.Contains(e, r.typ) {
+		return 1
+	}
+	return -1
+}
+
+func (e exprAny) appendTypes(types []string) []string {
+	return append(types, e...)
+}
+
+// Matches every row of the given types.
+type exprTrue []string
+
+func (e exprTrue) score(db *DB, r row) float64 {
+	return 1
+}
+
+func (e exprTrue) appendTypes(types []string) []string {
+	return append(types, e...)
+}
+
+// All operands must return a positive score. Result is the highest score.
+type exprAnd []expr
+
+func (e exprAnd) score(db *DB, r row) float64 {
+	result := 0.0
+	for _, op := range e {
+		s := op.score(db, r)
+		if s > result {
+			result = s
+		}
+		if s <= 0 {
+			result = s
+			break
+		}
+	}
+	return result
+}
+
+func (e exprAnd) appendTypes(types []string) []string {
+	for _, op := range e {
+		types = op.appendTypes(types)
+	}
+	return types
+}
+
+// At least one operand must return a positive score. Result is the first
+// matching score.
+type exprOr []expr
+
+func (e exprOr) score(db *DB, r row) float64 {
+	for _, op := range e {
+		if s := op.score(db, r); s > 0 {
+			return s
+		}
+	}
+	return -1
+}
+
+func (e exprOr) appendTypes(types []string) []string {
+	for _, op := range e {
+		types = op.appendTypes(types)
+	}
+	return types
+}
+
+// Negates the operand. Zero, indicating undefined, is still propagated as zero.
+type exprNot struct {
+	operand expr
+}
+
+func (e exprNot) score(db *DB, r row) float64 {
+	return math.Max(-1, -e.operand.score(db, r))
+}
+
+func (e exprNot) appendTypes(types []string) []string {
+	return e.operand.appendTypes(types)
+}
+
+// Calls visit for each row of the given types that matches e.
+func (db *DB) visitRows(types []string, e expr, visit func(r row, score float64)) {
+	for _, typ := range types {
+		i := slices.Index(db.EntityTypes, typ)
+		if i < 0 {
+			continue
+		}
+		base := 0
+		for _, table := range db.tables[:i] {
+			base += len(table) / db.rowSize
+		}
+		table := db.tables[i]
+		for j := 0; j+db.rowSize <= len(table); j += db.rowSize {
+			r := row{typ: typ, data: table[j : j+db.rowSize], index: base + j/db.rowSize}
+			if score := e.score(db, r); score > 0 {
+				visit(r, score)
+			}
+		}
+	}
+}
+
+// Performs a search of db using q. Results are sorted descending by score.
+func (db *DB) Search(q *Query) []Result {
+	var results []Result
+	for _, msg := range q.messages {
+		results = append(results, Result{Score: 1000, Value: msg})
+	}
+	e := q.expr
+	if e == nil && len(q.list) > 0 {
+		// With no query, select all rows.
+		e = exprTrue(db.EntityTypes)
+	}
+	if e == nil {
+		return results
+	}
+
+	// Select only types relevant to the query.
+	types := e.appendTypes(nil)
+	seen := map[string]bool{}
+	types = slices.DeleteFunc(types, func(typ string) bool {
+		if seen[typ] {
+			return true
+		}
+		seen[typ] = true
+		return false
+	})
+
+	var final []Result
+	if len(q.list) > 0 {
+		// List given fields of each matching row.
+		var values []Result
+		for _, l := range q.list {
+			switch l.kind {
+			case listKinds:
+				t := types
+				if len(t) == 0 {
+					t = l.types
+				}
+				db.visitRows(t, e, func(r row, score float64) {
+					values = append(values, Result{Score: 1, Value: r.typ})
+				})
+			case listTags:
+				// Every entity is considered as having every tag, so
+				// unconditionally list all tags.
+				for _, tag := range db.Tags {
+					values = append(values, Result{Score: 1, Value: tag})
+				}
+			case listFields:
+				// List all possible fields for matching rows.
+				sels := selectors(db.kinds())
+				db.visitRows(types, e, func(r row, score float64) {
+					for _, sel := range sels {
+						if slices.Contains(sel.list, r.typ) {
+							values = append(values, Result{Score: score, Value: sel.name})
+						}
+					}
+				})
+			default:
+				// List values of fields of matching rows.
+				db.visitRows(l.types, e, func(r row, score float64) {
+					if v, ok := db.value(r, l.col); ok {
+						values = append(values, Result{Score: score, Value: v})
+					}
+				})
+			}
+		}
+
+		// Group results by value, summing scores.
+		ids := map[any]int{}
+		for _, v := range values {
+			if i, ok := ids[v.Value]; ok {
+				final[i].Score += v.Score
+				continue
+			}
+			ids[v.Value] = len(final)
+			final = append(final, v)
+		}
+	} else {
+		// List each matching row, grouped by identifier.
+		type id struct{ typ, primary, secondary string }
+		ids := map[id]bool{}
+		for _, typ := range types {
+			db.visitRows([]string{typ}, e, func(r row, score float64) {
+				result := Result{
+					Score:     score,
+					Type:      r.typ,
+					Primary:   db.string(r, colPrimary),
+					Secondary: db.string(r, colSecondary),
+				}
+				id := id{result.Type, result.Primary, result.Secondary}
+				if ids[id] {
+					return
+				}
+				ids[id] = true
+				final = append(final, result)
+			})
+		}
+	}
+
+	// Sort results descending by score.
+	slices.SortStableFunc(final, func(a, b Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return append(results, final...)
+}
@@ -34,6 +34,8 @@ const DECIMAL       = ".";
 const INF           = "inf";
 const NAN           = "nan";
 const DIGITS        = /^\d+/;
+const GUID          = /^version-\w+/i;
+const DATE          = /^\d{4}-\d{2}-\d{2}/;
 
 export const all = Symbol("all");
 
@@ -225,6 +227,12 @@ const rules = ({rule, ref, lit, seq, alt, opt, rep, exc, init, name, ignoreCase,
 				flag: x.toLowerCase(),
 			};
 		}),
+		field(`doc`, ref("word"), (a,x)=>{
+			return {expr: "doc",
+				types: DB.T.ALL,
+				token: x.toLowerCase(),
+			};
+		}),
 		field(`removed`, ref("bool"), (a,x)=>{
 			return {expr: "op",
 				types: DB.T.ALL,
@@ -232,6 +240,27 @@ const rules = ({rule, ref, lit, seq, alt, opt, rep, exc, init, name, ignoreCase,
 				...x,
 			};
 		}),
+		field(`added`, ref("update_expr"), (a,x)=>{
+			return {expr:"op",
+				types: DB.T.ALL.filter(t => t !== DB.T.TYPE),
+				field: F.ADDED,
+				...x,
+			};
+		}),
+		field(`changedin`, ref("update_expr"), (a,x)=>{
+			return {expr:"op",
+				types: DB.T.ALL.filter(t => t !== DB.T.TYPE),
+				field: F.CHANGED_IN,
+				...x,
+			};
+		}),
+		field(`removedin`, ref("update_expr"), (a,x)=>{
+			return {expr:"op",
+				types: DB.T.ALL.filter(t => t !== DB.T.TYPE),
+				field: F.REMOVED_IN,
+				...x,
+			};
+		}),
 		field(`superclasses`, ref("number_expr"), (a,x)=>{
 			return {expr:"op",
 				types: [DB.T.CLASS],
@@ -537,9 +566,10 @@ const rules = ({rule, ref, lit, seq, alt, opt, rep, exc, init, name, ignoreCase,
 		).set(),
 	).call((a,x) => (x.length==1 ? x[0] : {expr: "and", operands: x})))
 
-	// Selector matching primary or secondary name.
+	// Selector matching primary or secondary name. A bare word also matches
+	// documentation.
 	rule("name", ref("string_expr").call((a,x) => {
-		return {expr:"or", operands:[
+		const expr = {expr:"or", operands:[
 			{expr:"op",
 				types: DB.T.PRIMARY,
 				field: F.PRIMARY,
@@ -551,6 +581,13 @@ const rules = ({rule, ref, lit, seq, alt, opt, rep, exc, init, name, ignoreCase,
 				...x,
 			},
 		]};
+		if (x.method === M.FUZZY) {
+			return {expr:"or", operands:[expr, {expr:"doc",
+				types: DB.T.ALL,
+				token: x.args[0].toLowerCase(),
+			}]};
+		};
+		return expr;
 	}))
 
 	// Selector that tries to match a numeric expression, then falls back to
@@ -707,6 +744,47 @@ const rules = ({rule, ref, lit, seq, alt, opt, rep, exc, init, name, ignoreCase,
 		ignoreCase().lit(NAN).set(NaN),
 	))
 	rule("digits", lit(DIGITS))
+
+	// Selectors for updates, by GUID or by date.
+	rule("update_expr", alt(
+		ref("all"),
+		ref("guid"),
+		ref("date_range"),
+		ref("date_operation"),
+	))
+	rule("guid", lit(GUID).call((a,x)=>({method:M.GUID, args:[x]})))
+	rule("date_range", init(()=>[]).seq(
+		ref("date").append(),
+		lit(RANGE),
+		ref("date").append(),
+	).call((a,x)=>({method:M.DAYS, args:x})))
+	rule("date_operation", init(()=>({op:"=",days:0})).seq(
+		opt(ref("date_op").field("op")),
+		ref("date").field("days"),
+	).call((a,x)=>{
+		switch (x.op) {
+		case LE:
+			return {method:M.DAYS, args:[-Infinity, x.days]};
+		case LT:
+			return {method:M.DAYS, args:[-Infinity, x.days-1]};
+		case GE:
+			return {method:M.DAYS, args:[x.days, Infinity]};
+		case GT:
+			return {method:M.DAYS, args:[x.days+1, Infinity]};
+		};
+		return {method:M.DAYS, args:[x.days, x.days]};
+	}))
+	rule("date_op", alt(
+		lit(LE).set(LE),
+		lit(LT).set(LT),
+		lit(GE).set(GE),
+		lit(GT).set(GT),
+	))
+	// Date of the form YYYY-MM-DD, as the number of days since the Unix epoch.
+	rule("date", name("date").lit(DATE).call((a,x)=>{
+		const [y, m, d] = x.split("-").map(Number);
+		return Date.UTC(y, m-1, d)/86400000;
+	}))
 };
 return [()=>grammar.make(rules, globalValue), ()=>grammar.print(rules)];
 };
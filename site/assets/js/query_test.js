@@ -4,17 +4,23 @@ import * as queryGrammar from "./query.js"
 function TESTS(DB, F, M) {
 	function fuzzy(value) {
 		return {expr:"or", operands:[
-			{expr:"op",
-				types:DB.T.PRIMARY,
-				field:F.PRIMARY,
-				method:M.FUZZY,
-				args:[value],
-			},
-			{expr:"op",
-				types:DB.T.SECONDARY,
-				field:F.SECONDARY,
-				method:M.FUZZY,
-				args:[value],
+			{expr:"or", operands:[
+				{expr:"op",
+					types:DB.T.PRIMARY,
+					field:F.PRIMARY,
+					method:M.FUZZY,
+					args:[value],
+				},
+				{expr:"op",
+					types:DB.T.SECONDARY,
+					field:F.SECONDARY,
+					method:M.FUZZY,
+					args:[value],
+				},
+			]},
+			{expr:"doc",
+				types:DB.T.ALL,
+				token:value.toLowerCase(),
 			},
 		]};
 	}
@@ -18,40 +18,102 @@ function u16(dv, offset) {
 	return dv.getUint16(offset, true);
 };
 
-function u24(dv, offset) {
-	return dv.getUint16(offset, true) + (dv.getUint8(offset+2) << 16);
-};
-
 function u32(dv, offset) {
 	return dv.getUint32(offset, true);
 };
 
+// Unsigned integer of n bytes.
+function uN(dv, offset, n) {
+	let v = 0;
+	for (let i = n-1; i >= 0; i--) {
+		v = v*256 + dv.getUint8(offset+i);
+	};
+	return v;
+};
+
+// Variable-length unsigned integer. Returns the value and the number of bytes
+// read.
+function uvarint(dv, offset) {
+	let v = 0;
+	for (let i = 0; ; i++) {
+		const b = dv.getUint8(offset+i);
+		v += (b & 0x7F) * 2**(7*i);
+		if ((b & 0x80) === 0) {
+			return [v, i+1];
+		};
+	};
+};
+
+// Identifies a file as a search database.
+const MAGIC = "RSDB";
+// Supported version of the database format.
+const VERSION = 6;
+
+// Encodings of the rows of data tables.
+const FORMAT_FIXED   = 0;
+const FORMAT_COMPACT = 1;
+
+// Flags indicating the optional sections present in the database.
+const SECTION_DOCS    = 1<<0;
+const SECTION_HISTORY = 1<<1;
+
+// Score of a row matching a documentation token. Less than any positive score
+// of a fuzzy match, so that documentation matches are sorted after name
+// matches.
+const DOC_SCORE = 0.5;
+
+// Maps the encoding codes of the header to decoding methods.
+const METHODS = [e0, e1, e2, sN, fN, n1, n2, n4, b1, i4];
+
 class Database {
 	constructor(buf) {
 		this.buf = buf;
 		this.data = new DataView(buf);
-		this.LEN_STRINGS = u16(this.data, 0);
-		this.LEN_BLOB    = u24(this.data, 2);
-		this.LEN_TYPES   = u8(this.data, 5);
-		this.LEN_TAGS    = u8(this.data, 6);
-		this.LEN_SECS    = u8(this.data, 7);
-		this.LEN_SAFES   = u8(this.data, 8);
-		this.LEN_CATS    = u8(this.data, 9);
-
-		this.OFF_STRINGS = 10 + this.LEN_TYPES*2;
-		this.OFF_BLOB    = this.OFF_STRINGS + this.LEN_STRINGS;
+		const magic = new TextDecoder().decode(buf.slice(0, 4));
+		if (magic !== MAGIC) {
+			throw "not a search database";
+		};
+		this.VERSION = u8(this.data, 4);
+		if (this.VERSION !== VERSION) {
+			throw `unsupported database version ${this.VERSION}`;
+		};
+		this.SIZ_ROW     = u8(this.data, 5);
+		this.SIZ_STR     = u8(this.data, 6);
+		this.SIZ_FLAGS   = u8(this.data, 7);
+		this.SECTIONS    = u8(this.data, 8);
+		this.FORMAT      = u8(this.data, 9);
+		this.LEN_STRINGS = u32(this.data, 10);
+		this.LEN_BLOB    = u32(this.data, 14);
+		this.LEN_TYPES   = u8(this.data, 18);
+		this.LEN_TAGS    = u16(this.data, 19);
+		this.LEN_SECS    = u8(this.data, 21);
+		this.LEN_SAFES   = u8(this.data, 22);
+		this.LEN_CATS    = u8(this.data, 23);
+		if (this.FORMAT !== FORMAT_FIXED && this.FORMAT !== FORMAT_COMPACT) {
+			throw `unsupported table format ${this.FORMAT}`;
+		};
+
+		// String sizes are variable-length, so the blob follows the last size.
+		this.OFF_STRINGS = 24 + this.LEN_TYPES*4;
+		const sizes = Array(this.LEN_STRINGS);
+		let o = this.OFF_STRINGS;
+		for (let i = 0; i < this.LEN_STRINGS; i++) {
+			const [z, n] = uvarint(this.data, o);
+			sizes[i] = z;
+			o += n;
+		};
+		this.OFF_BLOB    = o;
 		this.OFF_TYPES   = this.OFF_BLOB + this.LEN_BLOB;
-		this.OFF_TAGS    = this.OFF_TYPES + this.LEN_TYPES;
-		this.OFF_SECS    = this.OFF_TAGS + this.LEN_TAGS;
-		this.OFF_SAFES   = this.OFF_SECS + this.LEN_SECS;
-		this.OFF_CATS    = this.OFF_SAFES + this.LEN_SAFES;
-		this.OFF_ROWS    = this.OFF_CATS + this.LEN_CATS;
-		this.SIZ_ROW     = 23;
+		this.OFF_TAGS    = this.OFF_TYPES + this.LEN_TYPES*this.SIZ_STR;
+		this.OFF_SECS    = this.OFF_TAGS + this.LEN_TAGS*this.SIZ_STR;
+		this.OFF_SAFES   = this.OFF_SECS + this.LEN_SECS*this.SIZ_STR;
+		this.OFF_CATS    = this.OFF_SAFES + this.LEN_SAFES*this.SIZ_STR;
+		this.OFF_FIELDS  = this.OFF_CATS + this.LEN_CATS*this.SIZ_STR;
 
 		this.strings = Array(this.LEN_STRINGS);
 		const d = new TextDecoder();
 		for (let i=0, o=0; i < this.LEN_STRINGS; i++) {
-			const z = u8(this.data, this.OFF_STRINGS+i);
+			const z = sizes[i];
 			const s = buf.slice(this.OFF_BLOB+o, this.OFF_BLOB+o+z);
 			this.strings[i] = d.decode(s);
 			o += z;
@@ -59,13 +121,13 @@ class Database {
 
 		this.types = Array(this.LEN_TYPES);
 		for (let i = 0; i < this.LEN_TYPES; i++) {
-			this.types[i] = this.strings[u8(this.data, this.OFF_TYPES + i)];
+			this.types[i] = this.strings[this.index(this.OFF_TYPES + i*this.SIZ_STR)];
 		};
 
 		this.tags = new Map();
 		this.tagsLower = new Map();
 		for (let i = 0; i < this.LEN_TAGS; i++) {
-			const tag = this.strings[u8(this.data, this.OFF_TAGS + i)];
+			const tag = this.strings[this.index(this.OFF_TAGS + i*this.SIZ_STR)];
 			// First bit reserved for removed flag.
 			this.tags.set(tag, i + 1);
 			this.tagsLower.set(tag.toLowerCase(), i + 1);
@@ -73,30 +135,136 @@ class Database {
 
 		this.secs = Array(this.LEN_SECS);
 		for (let i = 0; i < this.LEN_SECS; i++) {
-			this.secs[i] = this.strings[u8(this.data, this.OFF_SECS + i)];
+			this.secs[i] = this.strings[this.index(this.OFF_SECS + i*this.SIZ_STR)];
 		};
 
 		this.safes = Array(this.LEN_SAFES);
 		for (let i = 0; i < this.LEN_SAFES; i++) {
-			this.safes[i] = this.strings[u8(this.data, this.OFF_SAFES + i)];
+			this.safes[i] = this.strings[this.index(this.OFF_SAFES + i*this.SIZ_STR)];
 		};
 
 		this.cats = Array(this.LEN_CATS);
 		for (let i = 0; i < this.LEN_CATS; i++) {
-			this.cats[i] = this.strings[u8(this.data, this.OFF_CATS + i)];
+			this.cats[i] = this.strings[this.index(this.OFF_CATS + i*this.SIZ_STR)];
 		};
 
+		// Verify that each field is encoded as expected.
+		o = this.OFF_FIELDS;
+		for (let i = 0; i < this.LEN_TYPES; i++) {
+			const n = u8(this.data, o);
+			o += 1;
+			for (let j = 0; j < n; j++) {
+				const name = this.strings[this.index(o)];
+				const method = METHODS[u8(this.data, o+this.SIZ_STR)];
+				const offset = u8(this.data, o+this.SIZ_STR+1);
+				o += this.SIZ_STR+2;
+				const f = F[name];
+				if (f && (f[0] !== method || this.offset(f[1]) !== offset)) {
+					throw `unsupported encoding of field ${name}`;
+				};
+			};
+		};
+		this.OFF_ROWS = o;
+
+		// Rows of all tables. In the compact format, rows are expanded into a
+		// separate buffer, so that they can be read in the same way.
 		this.tables = new Map();
 		this.LEN_ROWS = 0;
-		this.EOF = this.OFF_ROWS;
 		for (let i = 0; i < this.LEN_TYPES; i++) {
-			const lenTypeTable = u16(this.data, 10+i*2);
+			this.LEN_ROWS += u32(this.data, 24+i*4);
+		};
+		this.rowBuf = buf;
+		let rows = null;
+		if (this.FORMAT === FORMAT_COMPACT) {
+			this.rowBuf = new ArrayBuffer(this.LEN_ROWS*this.SIZ_ROW);
+			rows = new Uint8Array(this.rowBuf);
+		};
+		this.EOF = this.OFF_ROWS;
+		for (let i = 0, start = 0; i < this.LEN_TYPES; i++) {
+			const lenTypeTable = u32(this.data, 24+i*4);
 			this.tables.set(this.types[i], {
-				offset: this.EOF,
+				offset: rows ? start*this.SIZ_ROW : this.EOF,
 				length: lenTypeTable,
+				start: start,
 			});
-			this.LEN_ROWS += lenTypeTable;
-			this.EOF += lenTypeTable*this.SIZ_ROW;
+			start += lenTypeTable;
+			if (!rows) {
+				this.EOF += lenTypeTable*this.SIZ_ROW;
+				continue;
+			};
+			// Each row is a bitmap indicating which bytes are set, followed
+			// by the set bytes. Unset bytes are all ones.
+			const sizBitmap = (this.SIZ_ROW+7)>>3;
+			for (let j = 0; j < lenTypeTable; j++) {
+				const bitmap = this.EOF;
+				this.EOF += sizBitmap;
+				const row = (this.tables.get(this.types[i]).start+j)*this.SIZ_ROW;
+				for (let k = 0; k < this.SIZ_ROW; k++) {
+					if (u8(this.data, bitmap+(k>>3)) & (1<<(k&7))) {
+						rows[row+k] = u8(this.data, this.EOF);
+						this.EOF++;
+					} else {
+						rows[row+k] = 0xFF;
+					};
+				};
+			};
+		};
+
+		// Maps documentation tokens to sets of row indices across all tables.
+		this.docs = null;
+		if (this.SECTIONS & SECTION_DOCS) {
+			this.docs = new Map();
+			const n = u32(this.data, this.EOF);
+			this.EOF += 4;
+			for (let i = 0; i < n; i++) {
+				const token = this.strings[this.index(this.EOF)];
+				this.EOF += this.SIZ_STR;
+				let [count, z] = uvarint(this.data, this.EOF);
+				this.EOF += z;
+				const rows = new Set();
+				for (let j = 0, row = 0; j < count; j++) {
+					const [delta, z] = uvarint(this.data, this.EOF);
+					this.EOF += z;
+					row += delta;
+					rows.add(row);
+				};
+				this.docs.set(token, rows);
+			};
+		};
+
+		// Updates in chronological order, and a map of row indices across all
+		// tables to the indices of the updates that added, last changed, and
+		// removed the entity of the row.
+		this.updates = [];
+		this.history = null;
+		if (this.SECTIONS & SECTION_HISTORY) {
+			const n = u32(this.data, this.EOF);
+			this.EOF += 4;
+			for (let i = 0; i < n; i++) {
+				const guid = this.strings[this.index(this.EOF)];
+				this.EOF += this.SIZ_STR;
+				const version = this.strings[this.index(this.EOF)];
+				this.EOF += this.SIZ_STR;
+				const date = u32(this.data, this.EOF);
+				this.EOF += 4;
+				this.updates.push(new Update(guid, version, date));
+			};
+			this.history = new Map();
+			const m = u32(this.data, this.EOF);
+			this.EOF += 4;
+			for (let i = 0, row = 0; i < m; i++) {
+				const [delta, z] = uvarint(this.data, this.EOF);
+				this.EOF += z;
+				row += delta;
+				// Indices are offset by one, so that zero indicates no update.
+				const updates = [];
+				for (let j = 0; j < 3; j++) {
+					const [v, z] = uvarint(this.data, this.EOF);
+					this.EOF += z;
+					updates.push(v-1);
+				};
+				this.history.set(row, updates);
+			};
 		};
 
 		console.assert(this.EOF === buf.byteLength, this);
@@ -116,6 +284,9 @@ class Database {
 			["primary"        , {field: F.PRIMARY            , types: this.T.ALL}],
 			["secondary"      , {field: F.SECONDARY          , types: this.T.SECONDARY}],
 			["removed"        , {field: F.REMOVED            , types: this.T.ALL}],
+			["added"          , {field: F.ADDED              , types: this.T.ALL.filter(x => x !== this.T.TYPE)}],
+			["changedin"      , {field: F.CHANGED_IN         , types: this.T.ALL.filter(x => x !== this.T.TYPE)}],
+			["removedin"      , {field: F.REMOVED_IN         , types: this.T.ALL.filter(x => x !== this.T.TYPE)}],
 			["superclasses"   , {field: F.SUPERCLASSES       , types: [this.T.CLASS]}],
 			["subclasses"     , {field: F.SUBCLASSES         , types: [this.T.CLASS]}],
 			["members"        , {field: F.MEMBERS            , types: [this.T.CLASS]}],
@@ -161,6 +332,24 @@ class Database {
 			};
 		};
 	};
+	// Returns the string index at the given offset.
+	index(offset) {
+		return uN(this.data, offset, this.SIZ_STR);
+	};
+	// Maps the canonical offset of a field, which assumes 2-byte string
+	// indices and 4-byte flags, to its offset within a row.
+	offset(i) {
+		const w = this.SIZ_STR;
+		const f = this.SIZ_FLAGS;
+		if (i < 4) {
+			return (i>>1)*w;
+		} else if (i < 8) {
+			return i - 4 + 2*w;
+		} else if (i < 15) {
+			return i - 8 + 2*w + f;
+		};
+		return ((i-15)>>1)*w + 2*w + f + 7;
+	};
 	length(type) {
 		return this.tables.get(type).length;
 	};
@@ -200,31 +389,48 @@ function e2(table, data, i, e) {
 	return table[e][v];
 };
 
-// String. Value is an index of an array of strings.
-function s2(table, data, i) {
-	const v = u16(data, i);
-	if (v == 0xFFFF) {
+// String. Value is an index of an array of strings, with a width determined by
+// the header.
+function sN(table, data, i) {
+	const v = uN(data, i, table.SIZ_STR);
+	if (v == 2**(8*table.SIZ_STR)-1) {
 		return undefined;
 	};
 	return table.strings[v];
 }
 
-// Flags. Value is a bit field. Bit representation is determined by header.
-function f4(table, data, i) {
-	const v = u32(data, i);
-	if (v == 0xFFFFFFFF) {
+// Flags. Value is a bit field with a width determined by the header, returned
+// as an array of bytes. Bit representation is determined by header.
+function fN(table, data, i) {
+	const v = new Uint8Array(data.buffer, data.byteOffset+i, table.SIZ_FLAGS);
+	if (v.every(b => b === 0xFF)) {
 		return undefined;
 	};
 	return v;
 }
 
-// 32-bit bit field. Returns nth flag.
+// Update referred to by the history of an entity. Field is not stored within
+// the row, so the global index of the row is received instead of row data.
+function hN(table, index, n) {
+	const updates = table.history && table.history.get(index);
+	if (!updates || updates[n] < 0) {
+		return undefined;
+	};
+	return table.updates[updates[n]];
+}
+
+// Returns whether the nth bit of flags is set.
+function hasFlag(flags, n) {
+	return (flags[n>>3] & (1<<(n&7))) !== 0;
+}
+
+// Bit field. Returns nth flag.
 function f1(table, data, i, n) {
-	const v = u32(data, i);
-	if (v == 0xFFFFFFFF) {
+	const v = fN(table, data, i);
+	if (v === undefined) {
 		return undefined;
 	};
-	return (v&(1<<n)) !== 0;
+	return hasFlag(v, n);
 }
 
 // uint8.
@@ -254,6 +460,15 @@ function n4(table, data, i) {
 	return v;
 }
 
+// int32, zigzag-encoded.
+function i4(table, data, i) {
+	const v = u32(data, i);
+	if (v == 0xFFFFFFFF) {
+		return undefined;
+	};
+	return (v >>> 1) ^ -(v & 1);
+}
+
 // 8-bit Bool.
 function b1(table, data, i) {
 	const v = u8(data, i);
@@ -270,29 +485,36 @@ function b1(table, data, i) {
 // with the correct type.
 //
 // The first element is a function that receives a Database, a DataView, and the
-// remaining elements.
+// remaining elements. The second element is the canonical offset of the field,
+// which assumes 2-byte string indices, and is adjusted by Database.offset.
 export const F = {
 	// Entity
 
-	PRIMARY      : [s2, 0],
-	SECONDARY    : [s2, 2],
-	FLAGS        : [f4, 4],
+	PRIMARY      : [sN, 0],
+	SECONDARY    : [sN, 2],
+	FLAGS        : [fN, 4],
 	REMOVED      : [f1, 4, 0],
 
+	// Entity history. Not stored within the row, so has no offset.
+
+	ADDED      : [hN, null, 0],
+	CHANGED_IN : [hN, null, 1],
+	REMOVED_IN : [hN, null, 2],
+
 	// Class
 
-	CLASS_NAME   : [s2,  0],
+	CLASS_NAME   : [sN,  0],
 	SUPERCLASSES : [n1,  8],
 	SUBCLASSES   : [n2,  9],
 	MEMBERS      : [n2, 11],
 	ANCESTOR     : [n1, 13],
-	SUPERCLASS   : [s2, 15],
-	SUBCLASS     : [s2, 17],
-	MEM_CAT      : [s2, 19],
+	SUPERCLASS   : [sN, 15],
+	SUBCLASS     : [sN, 17],
+	MEM_CAT      : [sN, 19],
 
 	// Member (property, function, event, callback)
 
-	MEMBER_NAME   : [s2,  2],
+	MEMBER_NAME   : [sN,  2],
 	THREAD_SAFETY : [e0, 13, "safes"],
 	SECURITY      : [e1, 13, "secs"],
 
@@ -303,9 +525,9 @@ export const F = {
 	READ_SECURITY   : [e1, 13, "secs"],
 	WRITE_SECURITY  : [e0, 14, "secs"],
 	VALUE_TYPE_CAT  : [e1, 14, "cats"],
-	VALUE_TYPE_NAME : [s2, 15],
-	CATEGORY        : [s2, 19],
-	DEFAULT         : [s2, 21],
+	VALUE_TYPE_NAME : [sN, 15],
+	CATEGORY        : [sN, 19],
+	DEFAULT         : [sN, 21],
 
 	// Function, event, callback
 
@@ -315,26 +537,26 @@ export const F = {
 	RETURN_TYPE_OPT  : [b1, 12],
 	PARAM_TYPE_CAT   : [e0, 14, "cats"],
 	RETURN_TYPE_CAT  : [e1, 14, "cats"],
-	RETURN_TYPE_NAME : [s2, 15],
-	PARAM_TYPE_NAME  : [s2, 17],
-	PARAM_NAME       : [s2, 19],
-	PARAM_DEFAULT    : [s2, 21],
+	RETURN_TYPE_NAME : [sN, 15],
+	PARAM_TYPE_NAME  : [sN, 17],
+	PARAM_NAME       : [sN, 19],
+	PARAM_DEFAULT    : [sN, 21],
 
 	// Enum
 
-	ENUM_NAME  : [s2, 0],
+	ENUM_NAME  : [sN, 0],
 	ENUM_ITEMS : [n2, 9],
 
 	// EnumItem
 
-	ITEM_NAME    : [s2,  2],
+	ITEM_NAME    : [sN,  2],
 	LEGACY_NAMES : [n1,  8],
-	ITEM_VALUE   : [n4,  9],
-	LEGACY_NAME  : [s2, 15],
+	ITEM_VALUE   : [i4,  9],
+	LEGACY_NAME  : [sN, 15],
 
 	// Type
 
-	TYPE_NAME : [s2,  0],
+	TYPE_NAME : [sN,  0],
 	TYPE_CAT  : [e0, 14, "cats"],
 };
 
@@ -390,6 +612,11 @@ export const M = {
 	N_LE: (field, value) => ((field <= value) ? Math.max(1, field) : -1),
 	N_GT: (field, value) => ((field >  value) ? Math.max(1, field) : -1),
 	N_GE: (field, value) => ((field >= value) ? Math.max(1, field) : -1),
+	// Matches if the field is an update that occurred between the given
+	// numbers of days since the Unix epoch, inclusive.
+	DAYS: (field, lower, upper) => ((lower <= field.days && field.days <= upper) ? 1 : -1),
+	// Matches if the field is an update with the given GUID, ignoring case.
+	GUID: (field, guid) => ((field.guid.toLowerCase() === guid.toLowerCase()) ? 1 : -1),
 };
 
 // Recursively checks if row matches expr.
@@ -419,7 +646,17 @@ function rowMatches(row, expr) {
 		if (!n) {
 			return 0;
 		};
-		return ((flags&(1<<n)) !== 0) ? 1 : -1;
+		return hasFlag(flags, n) ? 1 : -1;
+	case "doc":
+		// Skip if row and op types do not match.
+		if (!expr.types.includes(row.type)) {
+			return 0;
+		};
+		if (!row.db.docs) {
+			return 0;
+		};
+		const rows = row.db.docs.get(expr.token);
+		return (rows && rows.has(row.index)) ? DOC_SCORE : -1;
 	case "any":
 		// Skip if row and op types do not match.
 		return (expr.types.includes(row.type)) ? 1 : -1;
@@ -680,25 +917,45 @@ class SearchResults {
 	};
 };
 
+// An update of the API. Displayed as its GUID.
+class Update {
+	constructor(guid, version, date) {
+		this.guid = guid;
+		this.version = version;
+		this.date = date;
+	};
+	// Number of days between the Unix epoch and the date of the update.
+	get days() {
+		return Math.floor(this.date/86400);
+	};
+	toString() {
+		return this.guid;
+	};
+};
+
 class Row {
 	constructor(db, type, i) {
 		this.db = db;
 		this.type = type;
 		this.i = i;
+		this.index = db.tables.get(type).start + i;
 		this.o = db.tables.get(type).offset;
 		this.o += db.SIZ_ROW*i;
-		this.buf = db.buf.slice(this.o, this.o + db.SIZ_ROW);
+		this.buf = db.rowBuf.slice(this.o, this.o + db.SIZ_ROW);
 		this.data = new DataView(this.buf);
 	};
 	field(method) {
-		return method[0](this.db, this.data, method[1], method[2]);
+		if (method[1] === null) {
+			return method[0](this.db, this.index, method[2]);
+		};
+		return method[0](this.db, this.data, this.db.offset(method[1]), method[2]);
 	};
 	field_name(name) {
 		const method = F[name];
 		if (!method) {
 			return undefiend;
 		};
-		return method[0](this.db, this.data, method[1], method[2]);
+		return this.field(method);
 	};
 	get primary() { return this.field(F.PRIMARY) };
 	get secondary() { return this.field(F.SECONDARY) };
@@ -712,14 +969,14 @@ class Row {
 		if (!n) {
 			return undefined;
 		};
-		return (flags&(1<<n)) !== 0;
+		return hasFlag(flags, n);
 	};
 	get removed() {
 		const flags = this.flags;
 		if (flags === undefined) {
 			return undefined;
 		};
-		return (flags&(1<<0)) !== 0
+		return hasFlag(flags, 0);
 	};
 };
 